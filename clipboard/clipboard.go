@@ -0,0 +1,71 @@
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// command is a clipboard program and its arguments
+type command struct {
+	name string
+	args []string
+}
+
+// commands returns the clipboard programs to try, in order of preference
+func commands() []command {
+	var cmds []command
+	switch runtime.GOOS {
+	case "darwin":
+		cmds = append(cmds, command{"pbcopy", nil})
+	case "windows":
+		cmds = append(cmds, command{"clip", nil})
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, command{"wl-copy", nil})
+	}
+	return append(cmds,
+		command{"xclip", []string{"-selection", "clipboard"}},
+		command{"xsel", []string{"--clipboard", "--input"}},
+		command{"wl-copy", nil},
+		command{"clip.exe", nil}, // WSL
+		command{"termux-clipboard-set", nil},
+	)
+}
+
+// Copy puts text on the system clipboard using the first available
+// clipboard program
+func Copy(text string) error {
+	var tried []string
+	for _, c := range commands() {
+		path, err := exec.LookPath(c.name)
+		if err != nil {
+			tried = append(tried, c.name)
+			continue
+		}
+
+		// The output is not captured: xclip keeps running in the
+		// background to serve the selection and would hold the pipe open
+		cmd := exec.Command(path, c.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s failed: %v", c.name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard command found (tried %s)", strings.Join(unique(tried), ", "))
+}
+
+func unique(names []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package code
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Block is a fenced code block of an answer
+type Block struct {
+	Lang string
+	Code string
+}
+
+var fenceOpen = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+
+// Extract returns the fenced code blocks of a Markdown text in order. An
+// unterminated block at the end, as in a truncated answer, is included.
+func Extract(text string) []Block {
+	var blocks []Block
+	var current *Block
+	var fence string
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if current == nil {
+			if m := fenceOpen.FindStringSubmatch(line); m != nil {
+				current = &Block{Lang: strings.ToLower(m[2])}
+				fence = m[1]
+				lines = nil
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			current.Code = strings.Join(lines, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if current != nil && len(lines) > 0 {
+		current.Code = strings.Join(lines, "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// Select filters blocks to those in lang, if given, and then picks the
+// index-th one (counting from 1), if index is not 0
+func Select(blocks []Block, lang string, index int) ([]Block, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("the answer has no code blocks")
+	}
+
+	if lang != "" {
+		want := Normalize(lang)
+		var matching []Block
+		for _, block := range blocks {
+			if Normalize(block.Lang) == want {
+				matching = append(matching, block)
+			}
+		}
+		if len(matching) == 0 {
+			return nil, fmt.Errorf("the answer has no %s code blocks", lang)
+		}
+		blocks = matching
+	}
+
+	if index != 0 {
+		if index < 1 || index > len(blocks) {
+			return nil, fmt.Errorf("code block %d does not exist (the answer has %d matching code block(s))", index, len(blocks))
+		}
+		blocks = blocks[index-1 : index]
+	}
+	return blocks, nil
+}
+
+// Join returns the code of blocks separated by blank lines
+func Join(blocks []Block) string {
+	var parts []string
+	for _, block := range blocks {
+		parts = append(parts, block.Code)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// aliases maps alternative language names to the ones used below
+var aliases = map[string]string{
+	"sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"golang": "go", "py": "python", "python3": "python",
+	"js": "javascript", "node": "javascript", "ts": "typescript",
+	"rb": "ruby", "rs": "rust", "kt": "kotlin", "cs": "csharp", "c#": "csharp",
+	"c++": "cpp", "cc": "cpp", "yml": "yaml", "ps1": "powershell", "pwsh": "powershell",
+	"docker": "dockerfile", "make": "makefile", "md": "markdown", "patch": "diff",
+}
+
+// Normalize returns the canonical name of a code block language
+func Normalize(lang string) string {
+	lang = strings.ToLower(lang)
+	if alias, ok := aliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+var extensions = map[string]string{
+	"go": ".go", "python": ".py", "javascript": ".js", "typescript": ".ts",
+	"jsx": ".jsx", "tsx": ".tsx", "bash": ".sh", "fish": ".fish",
+	"powershell": ".ps1", "ruby": ".rb", "rust": ".rs", "java": ".java",
+	"kotlin": ".kt", "swift": ".swift", "c": ".c", "cpp": ".cpp", "csharp": ".cs",
+	"php": ".php", "perl": ".pl", "lua": ".lua", "r": ".r", "scala": ".scala",
+	"sql": ".sql", "json": ".json", "yaml": ".yaml", "toml": ".toml",
+	"xml": ".xml", "html": ".html", "css": ".css", "markdown": ".md",
+	"makefile": ".mk", "dockerfile": ".dockerfile", "diff": ".diff",
+	"ini": ".ini", "hcl": ".tf", "terraform": ".tf", "proto": ".proto",
+}
+
+// Extension returns the file extension for code in lang
+func Extension(lang string) string {
+	if ext, ok := extensions[Normalize(lang)]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// Save writes each block to its own file in dir and returns the paths.
+// Existing files are never overwritten.
+func Save(dir string, blocks []Block) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	var paths []string
+	n := 1
+	for _, block := range blocks {
+		var path string
+		for {
+			path = filepath.Join(dir, fmt.Sprintf("snippet-%d%s", n, Extension(block.Lang)))
+			n++
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+		}
+
+		// Scripts with a shebang line are made executable
+		mode := os.FileMode(0644)
+		if strings.HasPrefix(block.Code, "#!") {
+			mode = 0755
+		}
+		if err := os.WriteFile(path, []byte(strings.TrimRight(block.Code, "\n")+"\n"), mode); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,84 @@
+package compact
+
+import (
+	"fmt"
+	"strings"
+
+	"ask/config"
+	"ask/tokens"
+)
+
+// KeepTurns is the number of recent turns kept verbatim when compacting
+const KeepTurns = 4
+
+// instructions is the system prompt used to request a summary
+const instructions = `You compact conversation histories. Summarize the conversation below so it can replace the original messages as context for future questions. Keep facts, decisions, names, code identifiers, file names and open questions. Leave out pleasantries. Write in the third person as concise notes, without an introduction.`
+
+// Split divides history into the turns to summarize and the most recent
+// keep turns, which stay verbatim. A turn starts at a user message.
+func Split(history []config.ChatMessage, keep int) (older, recent []config.ChatMessage) {
+	turns := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		turns++
+		if turns == keep {
+			return history[:i], history[i:]
+		}
+	}
+	return nil, history
+}
+
+// Threshold returns the number of history tokens above which a context is
+// compacted automatically, or 0 if automatic compaction is disabled. Unless
+// configured it is three quarters of the model's prompt budget.
+func Threshold(cfg *config.Config, model string) int {
+	if cfg.CompactThreshold < 0 {
+		return 0
+	}
+	if cfg.CompactThreshold > 0 {
+		return cfg.CompactThreshold
+	}
+	return tokens.Budget(model, cfg.MaxContextTokens) * 3 / 4
+}
+
+// Needed reports whether history has grown past threshold tokens and has
+// turns old enough to summarize
+func Needed(model string, history []config.ChatMessage, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+	older, _ := Split(history, KeepTurns)
+	return len(older) > 0 && tokens.EstimateMessages(model, history) > threshold
+}
+
+// Request builds the messages asking the model to summarize older, folding
+// in the summary from any earlier compaction
+func Request(previousSummary string, older []config.ChatMessage) []config.ChatMessage {
+	var transcript strings.Builder
+	if previousSummary != "" {
+		fmt.Fprintf(&transcript, "Summary of the conversation before these messages:\n%s\n\n", previousSummary)
+	}
+	for _, msg := range older {
+		content := msg.Content
+		for _, call := range msg.ToolCalls {
+			content += fmt.Sprintf("\n[called %s(%s)]", call.Function.Name, call.Function.Arguments)
+		}
+		fmt.Fprintf(&transcript, "%s:\n%s\n\n", msg.Role, strings.TrimSpace(content))
+	}
+
+	return []config.ChatMessage{
+		{Role: "system", Content: instructions},
+		{Role: "user", Content: strings.TrimSpace(transcript.String())},
+	}
+}
+
+// SummaryMessage returns the message that stands in for the archived turns
+// when a request is sent
+func SummaryMessage(summary string) config.ChatMessage {
+	return config.ChatMessage{
+		Role:    "system",
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+}
@@ -9,25 +9,122 @@ import (
 	"time"
 )
 
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+
+	// DefaultProvider is used when no provider has been configured
+	DefaultProvider = ProviderOpenAI
+
+	// DefaultMaxInputBytes limits how much piped input is sent with a prompt
+	DefaultMaxInputBytes = 1 << 20
+)
+
 type Config struct {
-	APIKey         string             `json:"api_key"`
-	Model          string             `json:"model"`
-	History        []ChatMessage      `json:"history,omitempty"`
-	Contexts       map[string]Context `json:"contexts,omitempty"`
-	CurrentContext string             `json:"current_context,omitempty"`
+	APIKey           string               `json:"api_key,omitempty"` // legacy OpenAI key, migrated to APIKeys
+	APIKeys          map[string]string    `json:"api_keys,omitempty"`
+	Provider         string               `json:"provider,omitempty"`
+	BaseURLs         map[string]string    `json:"base_urls,omitempty"`
+	Model            string               `json:"model"`
+	MaxInputBytes    int64                `json:"max_input_bytes,omitempty"`
+	MaxContextTokens int                  `json:"max_context_tokens,omitempty"` // prompt budget, zero derives it from the model
+	CompactThreshold int                  `json:"compact_threshold,omitempty"`  // history tokens that trigger compaction, negative disables it
+	SystemPrompt     string               `json:"system_prompt,omitempty"`      // standing instructions for every context
+	Sampling         *Sampling            `json:"sampling,omitempty"`           // default sampling options for every context
+	Tools            *ToolsConfig         `json:"tools,omitempty"`              // local tools the model may call
+	MCPServers       map[string]MCPServer `json:"mcp_servers,omitempty"`        // MCP servers whose tools the model may call
+	Retry            *RetryConfig         `json:"retry,omitempty"`              // retries of rate limited and failed requests
+	Timeouts         *TimeoutConfig       `json:"timeouts,omitempty"`           // request timeouts
+	Usage            *UsageConfig         `json:"usage,omitempty"`              // prices and budget for the usage ledger
+	History          []ChatMessage        `json:"history,omitempty"`            // legacy, migrated to the context store
+	LegacyContexts   map[string]Context   `json:"contexts,omitempty"`           // legacy, migrated to the context store
+	CurrentContext   string               `json:"current_context,omitempty"`
+
+	contexts  map[string]*Context     // contexts read from the store, by ID
+	bases     map[string]*contextBase // contexts as they were read, for merging
+	dirty     map[string]bool         // contexts changed since they were read
+	deleted   map[string]bool         // contexts to remove from the store
+	loadedAll bool                    // whether every stored context has been read
+	base      []byte                  // config.json as it was loaded, for merging
+}
+
+// ToolsConfig controls the local tools the model may call
+type ToolsConfig struct {
+	Enabled         bool              `json:"enabled,omitempty"`          // offer tools on every request
+	Policies        map[string]string `json:"policies,omitempty"`         // tool name to always, ask or never
+	AllowedCommands []string          `json:"allowed_commands,omitempty"` // commands run_command may run
+}
+
+// UsageConfig sets the prices and budget for usage reports
+type UsageConfig struct {
+	Prices        map[string]ModelPrice `json:"prices,omitempty"`         // per model, on top of the built-in prices
+	MonthlyBudget float64               `json:"monthly_budget,omitempty"` // in US dollars, zero for none
+}
+
+// ModelPrice is the price of a model in US dollars per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// TimeoutConfig overrides the default request timeouts, in seconds
+type TimeoutConfig struct {
+	ConnectSeconds   int `json:"connect_seconds,omitempty"`    // to connect to the provider
+	FirstByteSeconds int `json:"first_byte_seconds,omitempty"` // until the response starts
+	TotalSeconds     int `json:"total_seconds,omitempty"`      // for the whole request
+}
+
+// RetryConfig overrides the default retry policy
+type RetryConfig struct {
+	MaxAttempts     int `json:"max_attempts,omitempty"`     // attempts in total, 1 disables retries
+	DeadlineSeconds int `json:"deadline_seconds,omitempty"` // total time for all attempts
+}
+
+// MCPServer is an MCP server started over stdio
+type MCPServer struct {
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`      // added to the environment, $VARS are expanded
+	Policy   string            `json:"policy,omitempty"`   // default policy for its tools, "ask" if empty
+	Disabled bool              `json:"disabled,omitempty"` // keep the entry but do not start the server
 }
 
 type Context struct {
-	ID      string        `json:"id"`
-	Name    string        `json:"name"`
-	History []ChatMessage `json:"history"`
-	Created string        `json:"created"`
-	Updated string        `json:"updated"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	SystemPrompt string        `json:"system_prompt,omitempty"` // overrides the global system prompt
+	Persona      string        `json:"persona,omitempty"`       // persona used by default in this context
+	Sampling     *Sampling     `json:"sampling,omitempty"`      // overrides the global sampling options
+	History      []ChatMessage `json:"history"`
+	Summary      string        `json:"summary,omitempty"` // summary of the archived turns
+	Archive      []ChatMessage `json:"archive,omitempty"` // turns replaced by the summary
+	Created      string        `json:"created"`
+	Updated      string        `json:"updated"`
 }
 
+// TruncatedMarker ends a saved answer that was interrupted before it was
+// complete
+const TruncatedMarker = "[truncated: the answer was interrupted]"
+
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // tools the assistant asked to call
+	ToolCallID string     `json:"tool_call_id,omitempty"` // the call a "tool" message answers
+}
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall names the function to call and its JSON arguments
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 var (
@@ -42,19 +139,48 @@ func init() {
 	}
 	configDir = filepath.Join(homeDir, ".ask")
 	configFile = filepath.Join(configDir, "config.json")
+	contextsDir = filepath.Join(configDir, "contexts")
 }
 
 // Load loads the configuration from file
 func Load() (*Config, error) {
-	config := &Config{
-		Model: "gpt-3.5-turbo", // default model
+	config, err := readConfig()
+	if err != nil {
+		return nil, err
 	}
-
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+	if !config.hasLegacyContexts() {
 		return config, nil
 	}
 
+	// Migrate under the lock, reading the file again in case another ask
+	// process migrated it while we waited
+	release, err := lockStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate contexts: %v", err)
+	}
+	defer release()
+	if config, err = readConfig(); err != nil {
+		return nil, err
+	}
+	if err := migrateLegacyContexts(config); err != nil {
+		return nil, fmt.Errorf("failed to migrate contexts: %v", err)
+	}
+
+	return config, nil
+}
+
+// readConfig reads and parses config.json, upgrading settings stored by
+// older versions
+func readConfig() (*Config, error) {
+	config := &Config{
+		Model: DefaultModel(DefaultProvider), // default model
+	}
+
 	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		config.base, _ = json.Marshal(config)
+		return config, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
@@ -62,39 +188,179 @@ func Load() (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	config.base = data
+
+	// Older versions stored a single OpenAI key
+	if config.APIKey != "" {
+		if config.GetAPIKey(ProviderOpenAI) == "" {
+			config.SetAPIKey(ProviderOpenAI, config.APIKey)
+		}
+		config.APIKey = ""
+	}
 
 	return config, nil
 }
 
-// Save saves the configuration to file
+// Save saves the configuration to file. It holds the store lock while
+// writing and merges in changes other ask processes saved since this
+// configuration was loaded, so concurrent runs don't drop each other's
+// settings or conversation turns.
 func Save(config *Config) error {
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %v", err)
+	release, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return config.save()
+}
+
+// save writes the configuration and the changed contexts. The caller holds
+// the lock.
+func (c *Config) save() error {
+	if err := c.saveConfigFile(); err != nil {
+		return err
+	}
+
+	// Only contexts that changed are written back to the store
+	for id := range c.deleted {
+		if err := removeContext(id); err != nil {
+			return err
+		}
 	}
+	c.deleted = nil
+	for id := range c.dirty {
+		if err := c.saveContext(id); err != nil {
+			return err
+		}
+	}
+	c.dirty = nil
+
+	return nil
+}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+// saveConfigFile merges and writes config.json. The caller holds the lock.
+func (c *Config) saveConfigFile() error {
+	data, err := json.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	if err := os.WriteFile(configFile, data, 0600); err != nil {
+	onDisk, err := os.ReadFile(configFile)
+	if err == nil && c.base != nil {
+		if data, err = mergeJSON(c.base, data, onDisk); err != nil {
+			return fmt.Errorf("failed to merge config file: %v", err)
+		}
+	}
+
+	// Pick up the merged settings in memory too
+	merged := &Config{}
+	if err := json.Unmarshal(data, merged); err != nil {
+		return fmt.Errorf("failed to merge config file: %v", err)
+	}
+	merged.contexts, merged.bases, merged.dirty, merged.deleted, merged.loadedAll =
+		c.contexts, c.bases, c.dirty, c.deleted, c.loadedAll
+	*c = *merged
+
+	data, err = json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if err := WriteFileAtomic(configFile, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
+	c.base = data
 
 	return nil
 }
 
-// GetAvailableModels returns a list of available OpenAI models
-func GetAvailableModels() []string {
-	return []string{
-		"gpt-4.1-nano",
-		"gpt-4o",
-		"gpt-4o-mini",
-		"gpt-4-turbo",
-		"gpt-4",
-		"gpt-3.5-turbo",
-		"gpt-3.5-turbo-16k",
+// GetAvailableProviders returns the names of the supported providers
+func GetAvailableProviders() []string {
+	return []string{ProviderOpenAI, ProviderAnthropic, ProviderOllama}
+}
+
+// IsValidProvider reports whether name is a supported provider
+func IsValidProvider(name string) bool {
+	for _, provider := range GetAvailableProviders() {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultModel returns the model used for a provider when none is configured
+func DefaultModel(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "claude-sonnet-4-5"
+	case ProviderOllama:
+		return "llama3.2"
+	default:
+		return "gpt-3.5-turbo"
+	}
+}
+
+// GetProvider returns the configured provider, falling back to the default
+func (c *Config) GetProvider() string {
+	if c.Provider == "" {
+		return DefaultProvider
+	}
+	return c.Provider
+}
+
+// RequiresAPIKey reports whether requests to a provider need an API key.
+// Local servers such as Ollama, or any custom base URL, may run without one.
+func RequiresAPIKey(provider, baseURL string) bool {
+	return provider != ProviderOllama && baseURL == ""
+}
+
+// GetBaseURL returns the base URL configured for a provider, or an empty
+// string to use the provider's default endpoint
+func (c *Config) GetBaseURL(provider string) string {
+	return c.BaseURLs[provider]
+}
+
+// SetBaseURL stores the base URL for a provider
+func (c *Config) SetBaseURL(provider, baseURL string) {
+	if c.BaseURLs == nil {
+		c.BaseURLs = make(map[string]string)
+	}
+	if baseURL == "" {
+		delete(c.BaseURLs, provider)
+		return
+	}
+	c.BaseURLs[provider] = baseURL
+}
+
+// GetMaxInputBytes returns the maximum size of piped input
+func (c *Config) GetMaxInputBytes() int64 {
+	if c.MaxInputBytes <= 0 {
+		return DefaultMaxInputBytes
+	}
+	return c.MaxInputBytes
+}
+
+// GetAPIKey returns the API key stored for a provider
+func (c *Config) GetAPIKey(provider string) string {
+	return c.APIKeys[provider]
+}
+
+// SetAPIKey stores the API key for a provider
+func (c *Config) SetAPIKey(provider, key string) {
+	if c.APIKeys == nil {
+		c.APIKeys = make(map[string]string)
 	}
+	if key == "" {
+		delete(c.APIKeys, provider)
+		return
+	}
+	c.APIKeys[provider] = key
+}
+
+// GetConfigDir returns the directory holding all ask data
+func GetConfigDir() string {
+	return configDir
 }
 
 // GetConfigPath returns the path to the config file
@@ -125,29 +391,87 @@ func (c *Config) GetHistoryLength() int {
 	return len(c.History)
 }
 
-// Initialize contexts if not exists
+// InitContexts initializes the in-memory context cache
 func (c *Config) InitContexts() {
-	if c.Contexts == nil {
-		c.Contexts = make(map[string]Context)
+	if c.contexts == nil {
+		c.contexts = make(map[string]*Context)
+	}
+	if c.dirty == nil {
+		c.dirty = make(map[string]bool)
+	}
+	if c.deleted == nil {
+		c.deleted = make(map[string]bool)
+	}
+	if c.bases == nil {
+		c.bases = make(map[string]*contextBase)
+	}
+}
+
+// lookupContext returns a context by ID, reading it from the store on first use
+func (c *Config) lookupContext(id string) *Context {
+	c.InitContexts()
+
+	if context, ok := c.contexts[id]; ok {
+		return context
+	}
+	if c.loadedAll || c.deleted[id] {
+		return nil
+	}
+
+	context, data, err := readContext(id)
+	if err != nil || context == nil {
+		return nil
+	}
+	c.contexts[id] = context
+	c.bases[id] = &contextBase{data: data, historyLen: len(context.History)}
+	return context
+}
+
+// loadAllContexts reads every stored context into the cache
+func (c *Config) loadAllContexts() {
+	c.InitContexts()
+	if c.loadedAll {
+		return
+	}
+
+	ids, _ := listContextIDs()
+	for _, id := range ids {
+		c.lookupContext(id)
+	}
+	c.loadedAll = true
+}
+
+// markDirty records that a context must be written on the next Save
+func (c *Config) markDirty(id string) {
+	c.InitContexts()
+	c.dirty[id] = true
+}
+
+// markRewritten records that a context's history was replaced rather than
+// appended to, so it overrides the stored history on the next Save
+func (c *Config) markRewritten(id string) {
+	c.markDirty(id)
+	if base, ok := c.bases[id]; ok {
+		base.rewritten = true
 	}
 }
 
 // CreateNewContext creates a new conversation context
 func (c *Config) CreateNewContext(name string) (string, error) {
-	c.InitContexts()
+	c.loadAllContexts()
 
 	// Generate unique ID
 	id := generateID()
 
 	// Check if name already exists
-	for _, ctx := range c.Contexts {
+	for _, ctx := range c.contexts {
 		if ctx.Name == name {
 			return "", fmt.Errorf("context with name '%s' already exists", name)
 		}
 	}
 
-	now := time.Now().Format(time.RFC3339)
-	context := Context{
+	now := nowString()
+	context := &Context{
 		ID:      id,
 		Name:    name,
 		History: []ChatMessage{},
@@ -155,7 +479,8 @@ func (c *Config) CreateNewContext(name string) (string, error) {
 		Updated: now,
 	}
 
-	c.Contexts[id] = context
+	c.contexts[id] = context
+	c.markDirty(id)
 	c.CurrentContext = id
 
 	return id, nil
@@ -163,9 +488,7 @@ func (c *Config) CreateNewContext(name string) (string, error) {
 
 // SwitchContext switches to a different context
 func (c *Config) SwitchContext(contextID string) error {
-	c.InitContexts()
-
-	if _, exists := c.Contexts[contextID]; !exists {
+	if c.lookupContext(contextID) == nil {
 		return fmt.Errorf("context with ID '%s' not found", contextID)
 	}
 
@@ -173,38 +496,71 @@ func (c *Config) SwitchContext(contextID string) error {
 	return nil
 }
 
-// GetCurrentContext returns the current context
-func (c *Config) GetCurrentContext() *Context {
-	c.InitContexts()
-
+// currentContext returns the cached current context, creating a default
+// context if none exists
+func (c *Config) currentContext() *Context {
 	if c.CurrentContext == "" {
-		// Create default context if none exists
-		if len(c.Contexts) == 0 {
-			id, err := c.CreateNewContext("default")
-			if err != nil {
-				// This shouldn't happen with "default" name, but handle it gracefully
+		contexts := c.ListContexts()
+		if len(contexts) == 0 {
+			// Create default context if none exists
+			if err := c.createDefaultContext(); err != nil {
 				return nil
 			}
-			c.CurrentContext = id
 		} else {
-			// If contexts exist but none is selected, select the first one
-			for id := range c.Contexts {
-				c.CurrentContext = id
-				break
-			}
+			// If contexts exist but none is selected, select the most recent one
+			c.CurrentContext = contexts[0].ID
 		}
 	}
 
-	if context, exists := c.Contexts[c.CurrentContext]; exists {
-		return &context
+	context := c.lookupContext(c.CurrentContext)
+	if context == nil {
+		// The current context was deleted, e.g. from another terminal
+		c.CurrentContext = ""
+		return c.currentContext()
+	}
+	return context
+}
+
+// createDefaultContext creates and stores the default context. It is written
+// under the lock right away, after checking the store again, so that ask
+// processes started at once on a fresh install share one default context.
+func (c *Config) createDefaultContext() error {
+	release, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	c.loadedAll = false
+	if contexts := c.ListContexts(); len(contexts) > 0 {
+		c.CurrentContext = contexts[0].ID
+		return nil
 	}
 
+	id, err := c.CreateNewContext("default")
+	if err != nil {
+		return err
+	}
+	if err := c.saveContext(id); err != nil {
+		return err
+	}
+	delete(c.dirty, id)
 	return nil
 }
 
+// GetCurrentContext returns the current context
+func (c *Config) GetCurrentContext() *Context {
+	context := c.currentContext()
+	if context == nil {
+		return nil
+	}
+	copied := *context
+	return &copied
+}
+
 // GetCurrentContextHistory returns the history of the current context
 func (c *Config) GetCurrentContextHistory() []ChatMessage {
-	context := c.GetCurrentContext()
+	context := c.currentContext()
 	if context == nil {
 		return []ChatMessage{}
 	}
@@ -213,7 +569,7 @@ func (c *Config) GetCurrentContextHistory() []ChatMessage {
 
 // AddToCurrentContext adds a message to the current context
 func (c *Config) AddToCurrentContext(role, content string) {
-	context := c.GetCurrentContext()
+	context := c.currentContext()
 	if context == nil {
 		// Fall back to legacy history
 		c.AddToHistory(role, content)
@@ -224,13 +580,29 @@ func (c *Config) AddToCurrentContext(role, content string) {
 		Role:    role,
 		Content: content,
 	})
-	context.Updated = time.Now().Format(time.RFC3339)
-	c.Contexts[c.CurrentContext] = *context
+	context.Updated = nowString()
+	c.markDirty(context.ID)
+}
+
+// AppendToCurrentContext adds complete messages, such as tool calls and
+// their results, to the current context
+func (c *Config) AppendToCurrentContext(messages ...ChatMessage) {
+	context := c.currentContext()
+	if context == nil {
+		for _, msg := range messages {
+			c.AddToHistory(msg.Role, msg.Content)
+		}
+		return
+	}
+
+	context.History = append(context.History, messages...)
+	context.Updated = nowString()
+	c.markDirty(context.ID)
 }
 
 // ClearCurrentContext clears the history of the current context
 func (c *Config) ClearCurrentContext() {
-	context := c.GetCurrentContext()
+	context := c.currentContext()
 	if context == nil {
 		// Fall back to legacy history
 		c.ClearHistory()
@@ -238,19 +610,135 @@ func (c *Config) ClearCurrentContext() {
 	}
 
 	context.History = []ChatMessage{}
-	context.Updated = time.Now().Format(time.RFC3339)
-	c.Contexts[c.CurrentContext] = *context
+	context.Summary = ""
+	context.Archive = nil
+	context.Updated = nowString()
+	c.markRewritten(context.ID)
+}
+
+// GetSystemPrompt returns the system prompt in effect for the current
+// context: its own prompt if set, otherwise the global one
+func (c *Config) GetSystemPrompt() string {
+	if context := c.currentContext(); context != nil && context.SystemPrompt != "" {
+		return context.SystemPrompt
+	}
+	return c.SystemPrompt
+}
+
+// SetCurrentContextSystemPrompt sets the system prompt of the current
+// context. An empty prompt falls back to the global one.
+func (c *Config) SetCurrentContextSystemPrompt(prompt string) error {
+	context := c.currentContext()
+	if context == nil {
+		return fmt.Errorf("no current context")
+	}
+
+	context.SystemPrompt = prompt
+	context.Updated = nowString()
+	c.markDirty(context.ID)
+	return nil
+}
+
+// GetSampling returns the sampling options for a request: the global ones,
+// then those of the persona in use, if any, then the current context's
+func (c *Config) GetSampling(persona *Sampling) Sampling {
+	sampling := Sampling{}.Merge(c.Sampling).Merge(persona)
+	if context := c.currentContext(); context != nil {
+		sampling = sampling.Merge(context.Sampling)
+	}
+	return sampling
+}
+
+// SetCurrentContextSampling sets the sampling options of the current
+// context. nil uses the global ones.
+func (c *Config) SetCurrentContextSampling(sampling *Sampling) error {
+	context := c.currentContext()
+	if context == nil {
+		return fmt.Errorf("no current context")
+	}
+
+	context.Sampling = sampling
+	context.Updated = nowString()
+	c.markDirty(context.ID)
+	return nil
+}
+
+// SetCurrentContextPersona attaches a persona to the current context. An
+// empty name detaches it.
+func (c *Config) SetCurrentContextPersona(name string) error {
+	context := c.currentContext()
+	if context == nil {
+		return fmt.Errorf("no current context")
+	}
+
+	context.Persona = name
+	context.Updated = nowString()
+	c.markDirty(context.ID)
+	return nil
+}
+
+// DetachPersona detaches a persona from every context it is attached to,
+// e.g. because it was deleted, and returns the names of those contexts
+func (c *Config) DetachPersona(name string) []string {
+	var detached []string
+	for _, context := range c.ListContexts() {
+		if context.Persona != name {
+			continue
+		}
+		c.contexts[context.ID].Persona = ""
+		c.markDirty(context.ID)
+		detached = append(detached, context.Name)
+	}
+	return detached
+}
+
+// CompactCurrentContext replaces the oldest count messages of the current
+// context with a summary. The messages are moved to the archive so they can
+// be restored.
+func (c *Config) CompactCurrentContext(summary string, count int) error {
+	context := c.currentContext()
+	if context == nil {
+		return fmt.Errorf("no current context")
+	}
+	if count > len(context.History) {
+		count = len(context.History)
+	}
+
+	context.Archive = append(context.Archive, context.History[:count]...)
+	context.History = append([]ChatMessage{}, context.History[count:]...)
+	context.Summary = summary
+	context.Updated = nowString()
+	c.markRewritten(context.ID)
+	return nil
+}
+
+// RestoreCurrentContext moves the archived messages of the current context
+// back into its history and drops the summary. It returns the number of
+// messages restored.
+func (c *Config) RestoreCurrentContext() int {
+	context := c.currentContext()
+	if context == nil || len(context.Archive) == 0 {
+		return 0
+	}
+
+	restored := len(context.Archive)
+	context.History = append(append([]ChatMessage{}, context.Archive...), context.History...)
+	context.Archive = nil
+	context.Summary = ""
+	context.Updated = nowString()
+	c.markRewritten(context.ID)
+	return restored
 }
 
 // DeleteContext deletes a context
 func (c *Config) DeleteContext(contextID string) error {
-	c.InitContexts()
-
-	if _, exists := c.Contexts[contextID]; !exists {
+	if c.lookupContext(contextID) == nil {
 		return fmt.Errorf("context with ID '%s' not found", contextID)
 	}
 
-	delete(c.Contexts, contextID)
+	delete(c.contexts, contextID)
+	delete(c.dirty, contextID)
+	c.deleted[contextID] = true
 
 	// If we deleted the current context, clear it
 	if c.CurrentContext == contextID {
@@ -262,11 +750,11 @@ func (c *Config) DeleteContext(contextID string) error {
 
 // ListContexts returns all contexts
 func (c *Config) ListContexts() []Context {
-	c.InitContexts()
+	c.loadAllContexts()
 
 	var contexts []Context
-	for _, context := range c.Contexts {
-		contexts = append(contexts, context)
+	for _, context := range c.contexts {
+		contexts = append(contexts, *context)
 	}
 
 	// Sort by updated time (newest first)
@@ -281,3 +769,8 @@ func (c *Config) ListContexts() []Context {
 func generateID() string {
 	return fmt.Sprintf("ctx_%d", time.Now().UnixNano())
 }
+
+// nowString returns the current time in the format stored in contexts
+func nowString() string {
+	return time.Now().Format(time.RFC3339)
+}
@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// staleLockAge is how old a lock marker must be before it is assumed to be
+// left over from a crashed process
+const staleLockAge = time.Minute
+
+// tryLock emulates an exclusive lock with a marker file created next to f
+func tryLock(f *os.File) (bool, error) {
+	marker := f.Name() + ".held"
+	m, err := os.OpenFile(marker, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		return true, m.Close()
+	}
+	if !os.IsExist(err) {
+		return false, err
+	}
+	if info, statErr := os.Stat(marker); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+		os.Remove(marker)
+	}
+	return false, nil
+}
+
+func unlock(f *os.File) error {
+	return os.Remove(f.Name() + ".held")
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// mergeJSON performs a three-way merge of JSON objects. base is the object
+// as it was loaded, ours holds this process's changes and theirs is what is
+// currently on disk. Keys changed in ours win; all other keys take their
+// value from theirs, so changes made concurrently by other processes are
+// kept. Nested objects are merged key by key.
+func mergeJSON(base, ours, theirs []byte) ([]byte, error) {
+	var b, o, t map[string]json.RawMessage
+	if err := json.Unmarshal(base, &b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ours, &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(theirs, &t); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]json.RawMessage)
+	keys := make(map[string]bool)
+	for _, m := range []map[string]json.RawMessage{b, o, t} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	for k := range keys {
+		bv, inBase := b[k]
+		ov, inOurs := o[k]
+		tv, inTheirs := t[k]
+
+		if inOurs == inBase && (!inOurs || sameJSON(ov, bv)) {
+			// Unchanged here: keep whatever is on disk
+			if inTheirs {
+				merged[k] = tv
+			}
+			continue
+		}
+		if !inOurs {
+			// Deleted here
+			continue
+		}
+		if inBase && inTheirs && isObject(bv) && isObject(ov) && isObject(tv) {
+			nested, err := mergeJSON(bv, ov, tv)
+			if err != nil {
+				return nil, err
+			}
+			merged[k] = nested
+			continue
+		}
+		merged[k] = ov
+	}
+
+	return json.Marshal(merged)
+}
+
+// sameJSON reports whether two JSON values are semantically equal
+func sameJSON(a, b []byte) bool {
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// isObject reports whether a JSON value is an object
+func isObject(v []byte) bool {
+	var m map[string]json.RawMessage
+	return json.Unmarshal(v, &m) == nil && m != nil
+}
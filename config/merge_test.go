@@ -0,0 +1,86 @@
+package config
+
+import "testing"
+
+func TestMergeJSON(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+	}{
+		{
+			name: "unchanged here keeps theirs",
+			base: `{"model":"a"}`, ours: `{"model":"a"}`, theirs: `{"model":"b"}`,
+			want: `{"model":"b"}`,
+		},
+		{
+			name: "changed here wins over unchanged there",
+			base: `{"model":"a"}`, ours: `{"model":"b"}`, theirs: `{"model":"a"}`,
+			want: `{"model":"b"}`,
+		},
+		{
+			name: "changed on both sides: ours wins",
+			base: `{"model":"a"}`, ours: `{"model":"b"}`, theirs: `{"model":"c"}`,
+			want: `{"model":"b"}`,
+		},
+		{
+			name: "different keys changed on each side",
+			base: `{"model":"a","provider":"openai"}`, ours: `{"model":"b","provider":"openai"}`, theirs: `{"model":"a","provider":"ollama"}`,
+			want: `{"model":"b","provider":"ollama"}`,
+		},
+		{
+			name: "added on each side",
+			base: `{}`, ours: `{"model":"a"}`, theirs: `{"provider":"ollama"}`,
+			want: `{"model":"a","provider":"ollama"}`,
+		},
+		{
+			name: "deleted here",
+			base: `{"model":"a","system_prompt":"x"}`, ours: `{"model":"a"}`, theirs: `{"model":"a","system_prompt":"x"}`,
+			want: `{"model":"a"}`,
+		},
+		{
+			name: "deleted there",
+			base: `{"model":"a","system_prompt":"x"}`, ours: `{"model":"a","system_prompt":"x"}`, theirs: `{"model":"a"}`,
+			want: `{"model":"a"}`,
+		},
+		{
+			name:   "nested objects merged by key",
+			base:   `{"api_keys":{"openai":"k1"}}`,
+			ours:   `{"api_keys":{"openai":"k1","anthropic":"k2"}}`,
+			theirs: `{"api_keys":{"openai":"k3"}}`,
+			want:   `{"api_keys":{"openai":"k3","anthropic":"k2"}}`,
+		},
+		{
+			name: "nested object replaced when missing from base",
+			base: `{}`, ours: `{"api_keys":{"openai":"k1"}}`, theirs: `{"api_keys":{"ollama":"k2"}}`,
+			want: `{"api_keys":{"openai":"k1"}}`,
+		},
+		{
+			name: "arrays are replaced, not merged",
+			base: `{"list":[1]}`, ours: `{"list":[1,2]}`, theirs: `{"list":[1,3]}`,
+			want: `{"list":[1,2]}`,
+		},
+		{
+			name: "formatting differences are not changes",
+			base: `{"model": "a", "n": 1}`, ours: `{"model":"a","n":1.0}`, theirs: `{"model":"c","n":2}`,
+			want: `{"model":"c","n":2}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mergeJSON([]byte(test.base), []byte(test.ours), []byte(test.theirs))
+			if err != nil {
+				t.Fatalf("mergeJSON: %v", err)
+			}
+			if !sameJSON(got, []byte(test.want)) {
+				t.Errorf("mergeJSON = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeJSONInvalid(t *testing.T) {
+	if _, err := mergeJSON([]byte(`{}`), []byte(`{}`), []byte(`not json`)); err == nil {
+		t.Error("mergeJSON accepted invalid JSON")
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelInfo describes a model and the request options it accepts
+type ModelInfo struct {
+	Name          string // the model, or the prefix of its dated versions
+	Provider      string
+	ContextWindow int  // tokens of prompt and answer together
+	MaxOutput     int  // most tokens in an answer, zero if unknown
+	Vision        bool // accepts images
+	Tools         bool // can call tools
+	JSONMode      bool // can be forced to answer with JSON
+	Reasoning     bool // accepts a reasoning effort
+	NoSampling    bool // rejects temperature, top_p, penalties and stop sequences, like reasoning models
+	Listed        bool // offered in the built-in model list
+	Known         bool // false for the permissive defaults of unknown models
+}
+
+// defaultContextWindow is assumed for models not in the registry
+const defaultContextWindow = 8192
+
+// modelRegistry describes the known models. A model not listed by name
+// uses the entry with the longest name it starts with followed by "-" or
+// ":", so that dated versions such as gpt-4o-2024-08-06 and tags such as
+// llama3.2:1b share the entry of their model, but gpt-4.5 does not take
+// that of gpt-4.
+var modelRegistry = []ModelInfo{
+	// OpenAI
+	{Name: "gpt-5", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutput: 128000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	// The chat snapshot of GPT-5 is not a reasoning model
+	{Name: "gpt-5-chat", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutput: 16384, Vision: true, JSONMode: true},
+	{Name: "gpt-5-mini", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutput: 128000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	{Name: "gpt-5-nano", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutput: 128000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	{Name: "gpt-4.1", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutput: 32768, Vision: true, Tools: true, JSONMode: true},
+	{Name: "gpt-4.1-mini", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutput: 32768, Vision: true, Tools: true, JSONMode: true},
+	{Name: "gpt-4.1-nano", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutput: 32768, Vision: true, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gpt-4o", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutput: 16384, Vision: true, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gpt-4o-mini", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutput: 16384, Vision: true, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gpt-4-turbo", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutput: 4096, Vision: true, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gpt-4-32k", Provider: ProviderOpenAI, ContextWindow: 32768, MaxOutput: 4096, Tools: true},
+	{Name: "gpt-4", Provider: ProviderOpenAI, ContextWindow: 8192, MaxOutput: 8192, Tools: true, Listed: true},
+	{Name: "gpt-3.5-turbo", Provider: ProviderOpenAI, ContextWindow: 16385, MaxOutput: 4096, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gpt-3.5-turbo-16k", Provider: ProviderOpenAI, ContextWindow: 16385, MaxOutput: 4096, Tools: true, JSONMode: true, Listed: true},
+	{Name: "o1", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutput: 100000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	{Name: "o1-mini", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutput: 65536, NoSampling: true},
+	{Name: "o3", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutput: 100000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	{Name: "o3-mini", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutput: 100000, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+	{Name: "o4-mini", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutput: 100000, Vision: true, Tools: true, JSONMode: true, Reasoning: true, NoSampling: true},
+
+	// Anthropic
+	{Name: "claude-sonnet-4-5", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 64000, Vision: true, Tools: true, Listed: true},
+	{Name: "claude-sonnet-4", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 64000, Vision: true, Tools: true},
+	{Name: "claude-opus-4-5", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 64000, Vision: true, Tools: true},
+	{Name: "claude-opus-4-1", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 32000, Vision: true, Tools: true, Listed: true},
+	{Name: "claude-opus-4", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 32000, Vision: true, Tools: true},
+	{Name: "claude-haiku-4-5", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 64000, Vision: true, Tools: true, Listed: true},
+	{Name: "claude-3-7-sonnet", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 64000, Vision: true, Tools: true},
+	{Name: "claude-3-5-sonnet", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 8192, Vision: true, Tools: true},
+	{Name: "claude-3-5-haiku", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 8192, Tools: true},
+	{Name: "claude-3-5-haiku-latest", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 8192, Tools: true, Listed: true},
+	{Name: "claude-3-opus", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 4096, Vision: true, Tools: true},
+	{Name: "claude-3-haiku", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutput: 4096, Vision: true, Tools: true},
+
+	// Ollama
+	{Name: "llama3", Provider: ProviderOllama, ContextWindow: 8192, JSONMode: true},
+	{Name: "llama3.2", Provider: ProviderOllama, ContextWindow: 131072, Tools: true, JSONMode: true, Listed: true},
+	{Name: "llama3.1", Provider: ProviderOllama, ContextWindow: 131072, Tools: true, JSONMode: true, Listed: true},
+	{Name: "llama3.3", Provider: ProviderOllama, ContextWindow: 131072, Tools: true, JSONMode: true},
+	{Name: "qwen2.5", Provider: ProviderOllama, ContextWindow: 32768, Tools: true, JSONMode: true},
+	{Name: "qwen2.5-coder", Provider: ProviderOllama, ContextWindow: 32768, Tools: true, JSONMode: true, Listed: true},
+	{Name: "mistral", Provider: ProviderOllama, ContextWindow: 32768, Tools: true, JSONMode: true, Listed: true},
+	{Name: "gemma2", Provider: ProviderOllama, ContextWindow: 8192, JSONMode: true, Listed: true},
+}
+
+// LookupModel returns what is known about a model. Unknown models get
+// permissive defaults with Known unset and no output limit, so that no
+// option is rejected or capped.
+func LookupModel(name string) ModelInfo {
+	best := -1
+	for i, info := range modelRegistry {
+		if matchesModel(name, info.Name) && (best < 0 || len(info.Name) > len(modelRegistry[best].Name)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ModelInfo{Name: name, ContextWindow: defaultContextWindow, Vision: true, Tools: true, JSONMode: true, Reasoning: true}
+	}
+	info := modelRegistry[best]
+	info.Known = true
+	return info
+}
+
+// matchesModel reports whether name is model or a version or tag of it
+func matchesModel(name, model string) bool {
+	return name == model || strings.HasPrefix(name, model+"-") || strings.HasPrefix(name, model+":")
+}
+
+// ListedModels returns the models offered for a provider when its list
+// cannot be fetched
+func ListedModels(provider string) []ModelInfo {
+	var models []ModelInfo
+	for _, info := range modelRegistry {
+		if info.Listed && info.Provider == provider {
+			models = append(models, info)
+		}
+	}
+	return models
+}
+
+// GetAvailableModels returns the names of the listed models of a provider
+func GetAvailableModels(provider string) []string {
+	var names []string
+	for _, info := range ListedModels(provider) {
+		names = append(names, info.Name)
+	}
+	return names
+}
+
+// Capabilities summarizes a model, e.g. "128K context, 16K output; vision, tools, JSON"
+func (m ModelInfo) Capabilities() string {
+	if !m.Known {
+		return "unknown model"
+	}
+	sizes := formatTokens(m.ContextWindow) + " context"
+	if m.MaxOutput > 0 {
+		sizes += ", " + formatTokens(m.MaxOutput) + " output"
+	}
+	var features []string
+	if m.Vision {
+		features = append(features, "vision")
+	}
+	if m.Tools {
+		features = append(features, "tools")
+	}
+	if m.JSONMode {
+		features = append(features, "JSON")
+	}
+	if m.Reasoning {
+		features = append(features, "reasoning")
+	}
+	if m.NoSampling {
+		features = append(features, "no temperature")
+	}
+	if len(features) == 0 {
+		return sizes
+	}
+	return sizes + "; " + strings.Join(features, ", ")
+}
+
+// formatTokens formats a token count, e.g. 128000 as "128K"
+func formatTokens(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.0fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%dK", n/1000)
+	}
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		model      string
+		want       string
+		noSampling bool
+	}{
+		{"gpt-4o", "gpt-4o", false},
+		{"gpt-4o-2024-08-06", "gpt-4o", false},
+		{"gpt-4o-mini", "gpt-4o-mini", false},
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5-2025-08-07", "gpt-5", true},
+		{"gpt-5-chat-latest", "gpt-5-chat", false},
+		{"o1-mini-2024-09-12", "o1-mini", true},
+		{"claude-3-5-haiku-20241022", "claude-3-5-haiku", false},
+		{"llama3.2:1b", "llama3.2", false},
+		{"llama3:8b", "llama3", false},
+		{"llama3.3", "llama3.3", false},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini", false},
+		{"gpt-4-0613", "gpt-4", false},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5", false},
+		{"claude-sonnet-4-20250514", "claude-sonnet-4", false},
+		{"claude-opus-4-5", "claude-opus-4-5", false},
+	}
+	for _, test := range tests {
+		info := LookupModel(test.model)
+		if !info.Known || info.Name != test.want || info.NoSampling != test.noSampling {
+			t.Errorf("LookupModel(%s) = %s (known %v, no sampling %v), want %s (no sampling %v)",
+				test.model, info.Name, info.Known, info.NoSampling, test.want, test.noSampling)
+		}
+	}
+
+	// Names that only start like a known model are unknown, so that their
+	// options are not limited by another model's
+	for _, model := range []string{"my-local-model", "gpt-4.5-preview", "gpt-4oo", "llama3.4", "claude-next-5", "o1x"} {
+		if info := LookupModel(model); info.Known || info.ContextWindow != defaultContextWindow || !info.Tools || info.MaxOutput != 0 {
+			t.Errorf("LookupModel(%s) = %+v, want permissive defaults", model, info)
+		}
+	}
+}
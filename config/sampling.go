@@ -0,0 +1,53 @@
+package config
+
+// Sampling controls how the model generates its answer. Unset options use
+// the model's defaults.
+type Sampling struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        int      `json:"max_tokens,omitempty"` // longest answer
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`             // sequences that end the answer
+	ReasoningEffort  string   `json:"reasoning_effort,omitempty"` // minimal, low, medium or high
+}
+
+// IsZero reports whether no option is set
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.MaxTokens == 0 &&
+		s.PresencePenalty == nil && s.FrequencyPenalty == nil && s.Seed == nil &&
+		len(s.Stop) == 0 && s.ReasoningEffort == ""
+}
+
+// Merge returns s with the options set in other replacing its own
+func (s Sampling) Merge(other *Sampling) Sampling {
+	if other == nil {
+		return s
+	}
+	if other.Temperature != nil {
+		s.Temperature = other.Temperature
+	}
+	if other.TopP != nil {
+		s.TopP = other.TopP
+	}
+	if other.MaxTokens != 0 {
+		s.MaxTokens = other.MaxTokens
+	}
+	if other.PresencePenalty != nil {
+		s.PresencePenalty = other.PresencePenalty
+	}
+	if other.FrequencyPenalty != nil {
+		s.FrequencyPenalty = other.FrequencyPenalty
+	}
+	if other.Seed != nil {
+		s.Seed = other.Seed
+	}
+	if len(other.Stop) > 0 {
+		s.Stop = other.Stop
+	}
+	if other.ReasoningEffort != "" {
+		s.ReasoningEffort = other.ReasoningEffort
+	}
+	return s
+}
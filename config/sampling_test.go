@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestGetSampling(t *testing.T) {
+	float := func(f float64) *float64 { return &f }
+	tests := []struct {
+		name            string
+		global          *Sampling
+		persona         *Sampling
+		context         *Sampling
+		wantTemperature *float64
+	}{
+		{"nothing set", nil, nil, nil, nil},
+		{"global", &Sampling{Temperature: float(0.7)}, nil, nil, float(0.7)},
+		{"persona over global", &Sampling{Temperature: float(0.7)}, &Sampling{Temperature: float(0.2)}, nil, float(0.2)},
+		{"persona without temperature", &Sampling{Temperature: float(0.7)}, &Sampling{}, nil, float(0.7)},
+		{"context over persona", &Sampling{Temperature: float(0.7)}, &Sampling{Temperature: float(0.2)}, &Sampling{Temperature: float(0.1)}, float(0.1)},
+		{"context without temperature", nil, &Sampling{Temperature: float(0.2)}, &Sampling{MaxTokens: 100}, float(0.2)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTempDir(t)
+			cfg := &Config{Sampling: test.global}
+			if _, err := cfg.CreateNewContext("work"); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.SetCurrentContextSampling(test.context); err != nil {
+				t.Fatal(err)
+			}
+
+			got := cfg.GetSampling(test.persona).Temperature
+			switch {
+			case got == nil && test.wantTemperature == nil:
+			case got == nil || test.wantTemperature == nil || *got != *test.wantTemperature:
+				t.Errorf("temperature = %v, want %v", describe(got), describe(test.wantTemperature))
+			}
+		})
+	}
+}
+
+func describe(f *float64) interface{} {
+	if f == nil {
+		return "unset"
+	}
+	return *f
+}
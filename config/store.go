@@ -0,0 +1,234 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Conversations are kept out of config.json, with one file per context in
+// the contexts directory, so that a question only rewrites the context it
+// belongs to.
+
+var contextsDir string
+
+// GetContextsDir returns the directory holding the context files
+func GetContextsDir() string {
+	return contextsDir
+}
+
+// contextPath returns the file for a context, or false if the ID cannot be
+// a stored context
+func contextPath(id string) (string, bool) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", false
+	}
+	return filepath.Join(contextsDir, id+".json"), true
+}
+
+// contextBase is a context as it was read from the store, used to merge in
+// changes saved by other processes in the meantime
+type contextBase struct {
+	data       []byte
+	historyLen int
+	// rewritten is set when the history was replaced, e.g. cleared, rather
+	// than appended to
+	rewritten bool
+}
+
+// readContext reads a context from the store along with its raw contents.
+// It returns nil without an error if the context does not exist.
+func readContext(id string) (*Context, []byte, error) {
+	path, ok := contextPath(id)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read context %s: %v", id, err)
+	}
+
+	var context Context
+	if err := json.Unmarshal(data, &context); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse context %s: %v", id, err)
+	}
+	if context.ID == "" {
+		context.ID = id
+	}
+	return &context, data, nil
+}
+
+// writeContext writes a context to the store and returns what was written
+func writeContext(context *Context) ([]byte, error) {
+	path, ok := contextPath(context.ID)
+	if !ok {
+		return nil, fmt.Errorf("invalid context ID: %q", context.ID)
+	}
+	if err := os.MkdirAll(contextsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create contexts directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(context, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal context %s: %v", context.ID, err)
+	}
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write context %s: %v", context.ID, err)
+	}
+	return data, nil
+}
+
+// saveContext merges a changed context with the stored copy and writes it.
+// Messages appended here are added after any appended by other processes,
+// and a context another process deleted is not written again. The caller
+// holds the lock.
+func (c *Config) saveContext(id string) error {
+	context, ok := c.contexts[id]
+	if !ok {
+		return nil
+	}
+
+	merged := *context
+	base := c.bases[id]
+	stored, storedData, err := readContext(id)
+	if err != nil {
+		return err
+	}
+
+	if base != nil && stored == nil {
+		// Another ask process deleted the context since it was read
+		delete(c.contexts, id)
+		delete(c.bases, id)
+		return nil
+	}
+	if base != nil {
+		ours, err := json.Marshal(context)
+		if err != nil {
+			return fmt.Errorf("failed to marshal context %s: %v", id, err)
+		}
+		data, err := mergeJSON(base.data, ours, storedData)
+		if err != nil {
+			return fmt.Errorf("failed to merge context %s: %v", id, err)
+		}
+		merged = Context{}
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return fmt.Errorf("failed to merge context %s: %v", id, err)
+		}
+
+		if base.rewritten || base.historyLen > len(context.History) {
+			// Our rewritten history replaces the stored one, but turns other
+			// processes appended after we read it are kept
+			merged.History = context.History
+			if len(stored.History) > base.historyLen {
+				merged.History = append(append([]ChatMessage{}, context.History...), stored.History[base.historyLen:]...)
+			}
+		} else {
+			merged.History = append(append([]ChatMessage{}, stored.History...), context.History[base.historyLen:]...)
+		}
+	}
+
+	data, err := writeContext(&merged)
+	if err != nil {
+		return err
+	}
+	*context = merged
+	c.bases[id] = &contextBase{data: data, historyLen: len(merged.History)}
+	return nil
+}
+
+// removeContext deletes a context from the store
+func removeContext(id string) error {
+	path, ok := contextPath(id)
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete context %s: %v", id, err)
+	}
+	return nil
+}
+
+// listContextIDs returns the IDs of all stored contexts
+func listContextIDs() ([]string, error) {
+	entries, err := os.ReadDir(contextsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contexts directory: %v", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	return ids, nil
+}
+
+// hasLegacyContexts reports whether config.json still holds contexts or
+// history stored by older versions
+func (c *Config) hasLegacyContexts() bool {
+	return len(c.LegacyContexts) > 0 || len(c.History) > 0
+}
+
+// migrateLegacyContexts moves contexts and history stored inside config.json
+// by older versions into the context store. Context files are written before
+// the config is saved without them, so an interrupted migration is simply
+// repeated on the next run. The caller holds the lock.
+func migrateLegacyContexts(c *Config) error {
+	if !c.hasLegacyContexts() {
+		return nil
+	}
+
+	for id, legacy := range c.LegacyContexts {
+		context := legacy
+		if context.ID == "" {
+			context.ID = id
+		}
+		existing, _, err := readContext(context.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			if _, err := writeContext(&context); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The history from before contexts existed becomes a context of its own
+	if len(c.History) > 0 {
+		name := "default"
+		if len(c.LegacyContexts) > 0 {
+			name = "legacy history"
+		}
+		now := nowString()
+		context := Context{
+			ID:      generateID(),
+			Name:    name,
+			History: c.History,
+			Created: now,
+			Updated: now,
+		}
+		if _, err := writeContext(&context); err != nil {
+			return err
+		}
+		if c.CurrentContext == "" {
+			c.CurrentContext = context.ID
+		}
+	}
+
+	c.LegacyContexts = nil
+	c.History = nil
+	return c.save()
+}
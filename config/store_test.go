@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// useTempDir points the configuration and context store at a temporary
+// directory for the duration of a test
+func useTempDir(t *testing.T) {
+	t.Helper()
+	oldDir, oldFile, oldContexts := configDir, configFile, contextsDir
+	configDir = t.TempDir()
+	configFile = filepath.Join(configDir, "config.json")
+	contextsDir = filepath.Join(configDir, "contexts")
+	t.Cleanup(func() {
+		configDir, configFile, contextsDir = oldDir, oldFile, oldContexts
+	})
+}
+
+func mustLoad(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func mustSave(t *testing.T, cfg *Config) {
+	t.Helper()
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func TestSaveMergesConcurrentChanges(t *testing.T) {
+	useTempDir(t)
+	initial := mustLoad(t)
+	if _, err := initial.CreateNewContext("work"); err != nil {
+		t.Fatal(err)
+	}
+	initial.AddToCurrentContext("user", "first")
+	mustSave(t, initial)
+
+	a, b := mustLoad(t), mustLoad(t)
+	a.AddToCurrentContext("user", "from a")
+	a.SetAPIKey(ProviderOpenAI, "key-a")
+	b.AddToCurrentContext("user", "from b")
+	b.Model = "gpt-4o"
+	mustSave(t, a)
+	mustSave(t, b)
+
+	got := mustLoad(t)
+	if got.GetAPIKey(ProviderOpenAI) != "key-a" || got.Model != "gpt-4o" {
+		t.Errorf("settings = key %q, model %q; want both changes kept", got.GetAPIKey(ProviderOpenAI), got.Model)
+	}
+	var contents []string
+	for _, msg := range got.GetCurrentContextHistory() {
+		contents = append(contents, msg.Content)
+	}
+	if want := "[first from a from b]"; fmt.Sprint(contents) != want {
+		t.Errorf("history = %v, want %s", contents, want)
+	}
+}
+
+func TestConcurrentAppends(t *testing.T) {
+	useTempDir(t)
+	initial := mustLoad(t)
+	if _, err := initial.CreateNewContext("work"); err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, initial)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg, err := Load()
+			if err != nil {
+				errs <- err
+				return
+			}
+			cfg.AppendToCurrentContext(
+				ChatMessage{Role: "user", Content: fmt.Sprintf("question %d", i)},
+				ChatMessage{Role: "assistant", Content: fmt.Sprintf("answer %d", i)},
+			)
+			errs <- Save(cfg)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("writer failed: %v", err)
+		}
+	}
+
+	history := mustLoad(t).GetCurrentContextHistory()
+	if len(history) != 2*writers {
+		t.Fatalf("history has %d messages, want %d", len(history), 2*writers)
+	}
+	// Each exchange stays together
+	for i := 0; i < len(history); i += 2 {
+		var n int
+		fmt.Sscanf(history[i].Content, "question %d", &n)
+		if history[i+1].Content != fmt.Sprintf("answer %d", n) {
+			t.Errorf("message %d is %q after %q", i+1, history[i+1].Content, history[i].Content)
+		}
+	}
+}
+
+func TestClearKeepsConcurrentAppends(t *testing.T) {
+	useTempDir(t)
+	initial := mustLoad(t)
+	if _, err := initial.CreateNewContext("work"); err != nil {
+		t.Fatal(err)
+	}
+	initial.AddToCurrentContext("user", "old")
+	mustSave(t, initial)
+
+	a, b := mustLoad(t), mustLoad(t)
+	a.ClearCurrentContext()
+	b.AddToCurrentContext("user", "new")
+	mustSave(t, b)
+	mustSave(t, a)
+
+	history := mustLoad(t).GetCurrentContextHistory()
+	if len(history) != 1 || history[0].Content != "new" {
+		t.Errorf("history = %+v, want only the turn appended meanwhile", history)
+	}
+}
+
+func TestSaveDoesNotRecreateDeletedContext(t *testing.T) {
+	useTempDir(t)
+	initial := mustLoad(t)
+	id, err := initial.CreateNewContext("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, initial)
+
+	a, b := mustLoad(t), mustLoad(t)
+	b.AddToCurrentContext("user", "late turn")
+	if err := a.DeleteContext(id); err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, a)
+	mustSave(t, b)
+
+	if contexts := mustLoad(t).ListContexts(); len(contexts) != 0 {
+		t.Errorf("contexts = %+v, want the deleted context to stay deleted", contexts)
+	}
+}
+
+func TestConcurrentFirstRunsShareDefaultContext(t *testing.T) {
+	useTempDir(t)
+
+	const runs = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg, err := Load()
+			if err != nil {
+				errs <- err
+				return
+			}
+			cfg.AddToCurrentContext("user", fmt.Sprintf("question %d", i))
+			errs <- Save(cfg)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+	}
+
+	contexts := mustLoad(t).ListContexts()
+	if len(contexts) != 1 {
+		t.Fatalf("got %d contexts, want one default context", len(contexts))
+	}
+	if len(contexts[0].History) != runs {
+		t.Errorf("default context has %d messages, want %d", len(contexts[0].History), runs)
+	}
+}
+
+func TestConcurrentLegacyMigration(t *testing.T) {
+	useTempDir(t)
+	legacy := `{"model": "gpt-4o", "history": [{"role": "user", "content": "old question"}]}`
+	if err := os.WriteFile(configFile, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	const runs = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Load()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+	}
+
+	cfg := mustLoad(t)
+	contexts := cfg.ListContexts()
+	if len(contexts) != 1 {
+		t.Fatalf("got %d contexts, want the legacy history migrated once", len(contexts))
+	}
+	if cfg.CurrentContext != contexts[0].ID || len(contexts[0].History) != 1 {
+		t.Errorf("current context %q, migrated %+v", cfg.CurrentContext, contexts[0])
+	}
+}
+
+func TestDetachPersona(t *testing.T) {
+	useTempDir(t)
+	initial := mustLoad(t)
+	for _, name := range []string{"review", "other", "also review"} {
+		if _, err := initial.CreateNewContext(name); err != nil {
+			t.Fatal(err)
+		}
+		persona := "reviewer"
+		if name == "other" {
+			persona = "writer"
+		}
+		if err := initial.SetCurrentContextPersona(persona); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustSave(t, initial)
+
+	cfg := mustLoad(t)
+	detached := cfg.DetachPersona("reviewer")
+	sort.Strings(detached)
+	if fmt.Sprint(detached) != "[also review review]" {
+		t.Errorf("detached from %v, want [also review review]", detached)
+	}
+	mustSave(t, cfg)
+
+	for _, context := range mustLoad(t).ListContexts() {
+		want := ""
+		if context.Name == "other" {
+			want = "writer"
+		}
+		if context.Persona != want {
+			t.Errorf("context %s has persona %q, want %q", context.Name, context.Persona, want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment is a file attached to a prompt
+type Attachment struct {
+	Path     string
+	Language string
+	Content  string
+}
+
+// ignoredDirs are never descended into when attaching a directory
+var ignoredDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	".idea":        true,
+	".vscode":      true,
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+	".venv":        true,
+	"venv":         true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+}
+
+// languages maps file extensions to the language used to label code blocks
+var languages = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "jsx",
+	".ts":    "typescript",
+	".tsx":   "tsx",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".java":  "java",
+	".kt":    "kotlin",
+	".swift": "swift",
+	".c":     "c",
+	".h":     "c",
+	".cpp":   "cpp",
+	".cc":    "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".php":   "php",
+	".sh":    "bash",
+	".bash":  "bash",
+	".zsh":   "zsh",
+	".fish":  "fish",
+	".ps1":   "powershell",
+	".sql":   "sql",
+	".html":  "html",
+	".css":   "css",
+	".scss":  "scss",
+	".json":  "json",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".toml":  "toml",
+	".xml":   "xml",
+	".md":    "markdown",
+	".proto": "protobuf",
+	".tf":    "hcl",
+	".lua":   "lua",
+	".log":   "text",
+	".txt":   "text",
+}
+
+// filenameLanguages covers files recognised by name rather than extension
+var filenameLanguages = map[string]string{
+	"Makefile":   "makefile",
+	"Dockerfile": "dockerfile",
+	"go.mod":     "go",
+	"Gemfile":    "ruby",
+}
+
+// DetectLanguage returns the code block language for a file path
+func DetectLanguage(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := filenameLanguages[base]; ok {
+		return lang
+	}
+	if lang, ok := languages[strings.ToLower(filepath.Ext(base))]; ok {
+		return lang
+	}
+	return ""
+}
+
+// LoadFiles reads the files matched by paths, which may be files, globs or
+// directories. Directories are walked recursively, skipping common build
+// and VCS directories, anything excluded by .gitignore, and binary files.
+// A binary file named explicitly is an error. The combined size of all
+// files may not exceed limit bytes.
+func LoadFiles(paths []string, limit int64) ([]Attachment, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range paths {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", pattern)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", match, err)
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+			dirFiles, err := walkDir(match)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range dirFiles {
+				add(file)
+			}
+		}
+	}
+
+	var attachments []Attachment
+	var total int64
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if IsBinary(data) {
+			if !explicitlyNamed(path, paths) {
+				continue
+			}
+			return nil, fmt.Errorf("%s appears to be a binary file", path)
+		}
+		total += int64(len(data))
+		if total > limit {
+			return nil, fmt.Errorf("attached files exceed the maximum of %s (set max_input_bytes in the config to raise it)", FormatSize(limit))
+		}
+		attachments = append(attachments, Attachment{
+			Path:     path,
+			Language: DetectLanguage(path),
+			Content:  string(data),
+		})
+	}
+
+	return attachments, nil
+}
+
+// FormatAttachment renders a file as a labelled code block
+func FormatAttachment(a Attachment) string {
+	return fmt.Sprintf("File: %s\n%s", a.Path, Fence(a.Content, a.Language))
+}
+
+// explicitlyNamed reports whether path was given directly rather than found
+// through a glob or directory
+func explicitlyNamed(path string, paths []string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBinary reports whether data looks like a binary file
+func IsBinary(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	if len(sample) < len(data) {
+		// A multi-byte character may be cut off at the end of the sample
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return !utf8.Valid(sample)
+}
+
+// walkDir returns the files below root that are not ignored
+func walkDir(root string) ([]string, error) {
+	ignore := loadParentIgnores(root)
+	var files []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && ignoredDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			if path != root && ignore.Match(path, true) {
+				return filepath.SkipDir
+			}
+			ignore.Load(path)
+			return nil
+		}
+		if !info.Mode().IsRegular() || ignore.Match(path, false) {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %v", root, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// glob expands a pattern, supporting ** to match any number of directories
+func glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	pattern = filepath.Clean(pattern)
+	root := pattern[:strings.Index(pattern, "**")]
+	root = filepath.Dir(root + "x")
+	re, err := globRegexp(filepath.ToSlash(pattern), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root && ignoredDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		if re.MatchString(filepath.ToSlash(path)) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
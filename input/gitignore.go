@@ -0,0 +1,171 @@
+package input
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore file
+type ignoreRule struct {
+	base    string // directory containing the .gitignore
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList holds the .gitignore rules that apply during a directory walk
+type ignoreList struct {
+	rules []ignoreRule
+}
+
+// loadParentIgnores collects the .gitignore files from dir up to the root
+// of the enclosing git repository, if any
+func loadParentIgnores(dir string) *ignoreList {
+	list := &ignoreList{}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return list
+	}
+
+	var dirs []string
+	for current := filepath.Dir(abs); ; current = filepath.Dir(current) {
+		dirs = append([]string{current}, dirs...)
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(current) == current {
+			// Not inside a repository: only the walked directories apply
+			dirs = nil
+			break
+		}
+	}
+
+	for _, d := range dirs {
+		list.load(d, filepath.Join(d, ".gitignore"))
+	}
+	return list
+}
+
+// Load adds the rules of the .gitignore file in dir, if present
+func (l *ignoreList) Load(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	l.load(abs, filepath.Join(abs, ".gitignore"))
+}
+
+func (l *ignoreList) load(base, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		// Patterns without a slash match a name at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if !anchored {
+			line = "**/" + line
+		}
+
+		re, err := globRegexp(line, true)
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		l.rules = append(l.rules, rule)
+	}
+}
+
+// Match reports whether path is ignored. Later rules override earlier ones.
+func (l *ignoreList) Match(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range l.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rule.re.MatchString(filepath.ToSlash(rel)) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globRegexp converts a glob with ** support into a regular expression. When
+// prefix is true the pattern also matches everything below a matching path.
+func globRegexp(pattern string, prefix bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if prefix {
+		b.WriteString("(?:/.*)?")
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
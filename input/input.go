@@ -0,0 +1,70 @@
+package input
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// StdinMarker is the argument that explicitly requests reading from stdin
+const StdinMarker = "-"
+
+// ReadStdin reads all of r, failing if it holds more than limit bytes
+func ReadStdin(r io.Reader, limit int64) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return "", fmt.Errorf("input from stdin exceeds the maximum of %s (set max_input_bytes in the config to raise it)", FormatSize(limit))
+	}
+	return string(data), nil
+}
+
+// Compose combines the prompt given as arguments with piped text and
+// attached files. The argument acts as the instruction, with the piped text
+// and each file fenced below it.
+func Compose(instruction, piped string, files []Attachment) string {
+	var parts []string
+	if instruction != "" {
+		parts = append(parts, instruction)
+	}
+
+	piped = strings.TrimRight(piped, "\n")
+	if strings.TrimSpace(piped) != "" {
+		if len(parts) == 0 && len(files) == 0 {
+			// Piped text on its own is the prompt
+			return piped
+		}
+		parts = append(parts, Fence(piped, ""))
+	}
+
+	for _, file := range files {
+		parts = append(parts, FormatAttachment(file))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// Fence wraps text in a Markdown code block labelled with lang, using a
+// fence longer than any backtick run inside the text
+func Fence(text, lang string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+// FormatSize formats a byte count for error messages
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
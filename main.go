@@ -2,48 +2,80 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"ask/clipboard"
+	"ask/code"
+	"ask/compact"
 	"ask/config"
+	"ask/input"
+	"ask/mcp"
+	"ask/models"
+	"ask/output"
+	"ask/persona"
+	"ask/provider"
+	"ask/render"
+	"ask/repl"
+	"ask/sampling"
 	"ask/setup"
+	"ask/shell"
+	"ask/templates"
+	"ask/tokens"
+	"ask/tools"
+	"ask/usage"
 )
 
-type ChatRequest struct {
-	Model    string               `json:"model"`
-	Messages []config.ChatMessage `json:"messages"`
-}
-
-// ChatMessage is now defined in config package
-
-type ChatResponse struct {
-	Choices []struct {
-		Message config.ChatMessage `json:"message"`
-	} `json:"choices"`
-}
-
 func main() {
 	var (
-		setupFlag      = flag.Bool("setup", false, "Run the interactive setup process")
-		modelFlag      = flag.String("model", "", "Override the configured model for this request")
-		helpFlag       = flag.Bool("help", false, "Show help information")
-		showConfigFlag = flag.Bool("show-config", false, "Show the current configuration and exit")
-		editConfigFlag = flag.Bool("edit-config", false, "Edit the current configuration")
-		clearFlag      = flag.Bool("clear", false, "Clear conversation history")
-		noContextFlag  = flag.Bool("no-context", false, "Don't use conversation history for this request")
-		newContextFlag = flag.String("new-context", "", "Create a new context with the given name")
-		switchFlag     = flag.String("switch", "", "Switch to context by ID or name")
-		listFlag       = flag.Bool("list-contexts", false, "List all contexts")
-		deleteFlag     = flag.String("delete-context", "", "Delete context by ID or name")
+		setupFlag       = flag.Bool("setup", false, "Run the interactive setup process")
+		modelFlag       = flag.String("model", "", "Override the configured model for this request")
+		providerFlag    = flag.String("provider", "", "Override the configured provider for this request (openai, anthropic, ollama)")
+		baseURLFlag     = flag.String("base-url", "", "Override the provider's API base URL, e.g. for a local OpenAI-compatible server")
+		helpFlag        = flag.Bool("help", false, "Show help information")
+		showConfigFlag  = flag.Bool("show-config", false, "Show the current configuration and exit")
+		editConfigFlag  = flag.Bool("edit-config", false, "Edit the current configuration")
+		clearFlag       = flag.Bool("clear", false, "Clear conversation history")
+		noContextFlag   = flag.Bool("no-context", false, "Don't use conversation history for this request")
+		newContextFlag  = flag.String("new-context", "", "Create a new context with the given name")
+		switchFlag      = flag.String("switch", "", "Switch to context by ID or name")
+		listFlag        = flag.Bool("list-contexts", false, "List all contexts")
+		deleteFlag      = flag.String("delete-context", "", "Delete context by ID or name")
+		streamFlag      = flag.Bool("stream", false, "Stream the answer as it is generated (default when output is a terminal)")
+		noStreamFlag    = flag.Bool("no-stream", false, "Wait for the complete answer before printing it")
+		systemFlag      = flag.String("system", "", "Use this system prompt for this request only")
+		compactFlag     = flag.Bool("compact", false, "Summarize the older turns of the current context")
+		restoreFlag     = flag.Bool("restore-history", false, "Restore the turns archived by --compact")
+		interactiveFlag = flag.Bool("interactive", false, "Start an interactive chat (same as 'ask chat')")
+		personaFlag     = flag.String("persona", "", "Use a saved persona; with --new-context, attach it to the new context")
+		templateFlag    = flag.String("template", "", "Build the prompt from a template in ~/.ask/templates")
+		rawFlag         = flag.Bool("raw", false, "Print the answer as plain Markdown instead of rendering it")
+		outputFlag      = flag.String("output", output.FormatText, "Output format: text, json or jsonl")
+		codeFlag        = flag.Bool("code", false, "Print only the code blocks of the answer")
+		codeLangFlag    = flag.String("code-lang", "", "Only use code blocks in this language (implies --code)")
+		codeIndexFlag   = flag.Int("code-index", 0, "Only use the Nth code block, counting from 1 (implies --code)")
+		saveCodeFlag    = flag.String("save-code", "", "Save each code block of the answer to a file in this directory")
+		copyFlag        = flag.Bool("copy", false, "Copy the answer, or the code with --code, to the clipboard")
+		timeoutFlag     = flag.String("timeout", "", "Request timeout, e.g. 2m, or connect=5s,first-byte=30s,total=2m")
+		toolsFlag       = flag.Bool("tools", false, "Let the model read files, list directories and run allowed commands (openai only)")
+		fileFlags       stringList
+		varFlags        stringList
+		samplingFlags   config.Sampling
 	)
+	flag.Var(&fileFlags, "file", "Attach a file, glob or directory to the prompt (repeatable)")
+	flag.Var(&fileFlags, "f", "Shorthand for --file")
+	flag.Var(&varFlags, "var", "Set a template variable, as key=value or key=@file (repeatable)")
+	sampling.AddFlags(flag.CommandLine, &samplingFlags)
 	flag.Parse()
 
 	if *helpFlag {
@@ -65,16 +97,46 @@ func main() {
 		}
 		fmt.Println("Current Ask CLI configuration:")
 		fmt.Printf("  Config file: %s\n", config.GetConfigPath())
-		fmt.Printf("  API Key: %s\n", maskAPIKey(cfg.APIKey))
+		fmt.Printf("  Contexts directory: %s\n", config.GetContextsDir())
+		fmt.Printf("  Provider: %s\n", cfg.GetProvider())
+		for _, name := range config.GetAvailableProviders() {
+			if key := cfg.GetAPIKey(name); key != "" {
+				fmt.Printf("  API Key (%s): %s\n", name, maskAPIKey(key))
+			}
+			if baseURL := cfg.GetBaseURL(name); baseURL != "" {
+				fmt.Printf("  Base URL (%s): %s\n", name, baseURL)
+			}
+		}
 		fmt.Printf("  Model: %s\n", cfg.Model)
+		fmt.Printf("  Model capabilities: %s\n", config.LookupModel(cfg.Model).Capabilities())
+		fmt.Printf("  Max input size: %s\n", input.FormatSize(cfg.GetMaxInputBytes()))
+		if cfg.SystemPrompt != "" {
+			fmt.Printf("  Global system prompt: %s\n", cfg.SystemPrompt)
+		}
+		if cfg.Sampling != nil {
+			fmt.Printf("  Global sampling options: %s\n", sampling.Describe(*cfg.Sampling))
+		}
+		fmt.Printf("  Max context tokens: %d\n", tokens.Budget(cfg.Model, cfg.MaxContextTokens))
 		currentContext := cfg.GetCurrentContext()
 		if currentContext != nil {
 			contextType := ""
-			if currentContext.Name == "default" && len(cfg.Contexts) == 1 {
+			if currentContext.Name == "default" && len(cfg.ListContexts()) == 1 {
 				contextType = " (default)"
 			}
 			fmt.Printf("  Current context: %s%s (%s)\n", currentContext.Name, contextType, currentContext.ID)
-			fmt.Printf("  Conversation history: %d messages\n", len(currentContext.History))
+			if currentContext.SystemPrompt != "" {
+				fmt.Printf("  Context system prompt: %s\n", currentContext.SystemPrompt)
+			}
+			if currentContext.Persona != "" {
+				fmt.Printf("  Context persona: %s\n", currentContext.Persona)
+			}
+			if currentContext.Sampling != nil {
+				fmt.Printf("  Context sampling options: %s\n", sampling.Describe(*currentContext.Sampling))
+			}
+			fmt.Printf("  Conversation history: %d messages (~%d tokens)\n", len(currentContext.History), tokens.EstimateMessages(cfg.Model, currentContext.History))
+			if currentContext.Summary != "" {
+				fmt.Printf("  Compacted: %d archived messages replaced by a summary\n", len(currentContext.Archive))
+			}
 			if len(currentContext.History) > 0 {
 				fmt.Println("  Recent conversation:")
 				history := currentContext.History
@@ -114,7 +176,7 @@ func main() {
 	}
 
 	if *editConfigFlag {
-		if err := editConfig(); err != nil {
+		if err := editConfig(*baseURLFlag); err != nil {
 			log.Fatalf("Failed to edit configuration: %v", err)
 		}
 		return
@@ -133,19 +195,47 @@ func main() {
 		return
 	}
 
+	if *restoreFlag {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		restored := cfg.RestoreCurrentContext()
+		if restored == 0 {
+			fmt.Println("📦 Nothing to restore: the current context has no archived turns.")
+			return
+		}
+		if err := config.Save(cfg); err != nil {
+			log.Fatalf("Failed to save configuration: %v", err)
+		}
+		fmt.Printf("📦 Restored %d archived messages and removed the summary.\n", restored)
+		return
+	}
+
 	if *newContextFlag != "" {
 		cfg, err := config.Load()
 		if err != nil {
 			log.Fatalf("Failed to load configuration: %v", err)
 		}
+		if *personaFlag != "" && !persona.Exists(*personaFlag) {
+			log.Fatalf("Failed to create context: persona not found: %s", *personaFlag)
+		}
 		id, err := cfg.CreateNewContext(*newContextFlag)
 		if err != nil {
 			log.Fatalf("Failed to create context: %v", err)
 		}
+		if *personaFlag != "" {
+			if err := cfg.SetCurrentContextPersona(*personaFlag); err != nil {
+				log.Fatalf("Failed to attach persona: %v", err)
+			}
+		}
 		if err := config.Save(cfg); err != nil {
 			log.Fatalf("Failed to save configuration: %v", err)
 		}
 		fmt.Printf("✅ Created new context '%s' with ID: %s\n", *newContextFlag, id)
+		if *personaFlag != "" {
+			fmt.Printf("🎭 Using persona: %s\n", *personaFlag)
+		}
 		return
 	}
 
@@ -192,14 +282,121 @@ func main() {
 		return
 	}
 
+	// System prompt management: ask system show|set|clear [--global] [prompt]
+	if flag.NArg() >= 2 && flag.Arg(0) == "system" && isSystemAction(flag.Arg(1)) {
+		if err := runSystemCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to update system prompt: %v", err)
+		}
+		return
+	}
+
+	// Sampling options: ask sampling show|set|clear [--global] [options]
+	if flag.NArg() >= 2 && flag.Arg(0) == "sampling" && sampling.IsCommand(flag.Arg(1)) {
+		if err := sampling.RunCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to update sampling options: %v", err)
+		}
+		return
+	}
+
+	// Persona management: ask persona list|show|add|edit|remove [name]
+	if flag.NArg() >= 2 && flag.Arg(0) == "persona" && persona.IsCommand(flag.Arg(1)) {
+		if err := persona.RunCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Persona command failed: %v", err)
+		}
+		return
+	}
+
+	// Template listing: ask template [list|show name]
+	if flag.NArg() >= 1 && flag.Arg(0) == "template" && (flag.NArg() == 1 || templates.IsCommand(flag.Arg(1))) {
+		args := flag.Args()[1:]
+		if len(args) == 0 {
+			args = []string{"list"}
+		}
+		if err := templates.RunCommand(args); err != nil {
+			log.Fatalf("Template command failed: %v", err)
+		}
+		return
+	}
+
+	// Model lists: ask models [--provider name] [--refresh] [--offline] [--all] [--plain]
+	if flag.NArg() >= 1 && flag.Arg(0) == "models" && (flag.NArg() == 1 || strings.HasPrefix(flag.Arg(1), "-")) {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		chosen := *providerFlag
+		if chosen == "" {
+			chosen = cfg.GetProvider()
+		}
+		baseURL := func(name string) string {
+			if name == chosen {
+				return resolveBaseURL(cfg, name, *baseURLFlag)
+			}
+			return cfg.GetBaseURL(name)
+		}
+		if err := models.RunCommand(cfg, flag.Args()[1:], chosen, baseURL); err != nil {
+			log.Fatalf("Models command failed: %v", err)
+		}
+		return
+	}
+
+	// Usage reports: ask usage [--by day|model|context] [--month YYYY-MM|--days N|--all]
+	if flag.NArg() >= 1 && flag.Arg(0) == "usage" && (flag.NArg() == 1 || strings.HasPrefix(flag.Arg(1), "-")) {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if err := usage.RunCommand(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Usage report failed: %v", err)
+		}
+		return
+	}
+
+	// MCP servers: ask mcp [list|tools|resources|prompts [server]|read server uri]
+	if flag.NArg() >= 1 && flag.Arg(0) == "mcp" && (flag.NArg() == 1 || mcp.IsCommand(flag.Arg(1))) {
+		args := flag.Args()[1:]
+		if len(args) == 0 {
+			args = []string{"list"}
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if err := mcp.RunCommand(cfg, args); err != nil {
+			log.Fatalf("MCP command failed: %v", err)
+		}
+		return
+	}
+
+	// From here on failures are reported in the requested output format
+	// and exit with a stable code
+	format := *outputFlag
+	if !output.IsValidFormat(format) {
+		fail(output.FormatText, "Invalid output format", output.BadInput(fmt.Errorf("%s (available: text, json, jsonl)", format)))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fail(format, "Failed to load configuration", err)
 	}
 
-	// Check if API key is configured
-	if cfg.APIKey == "" {
+	// Determine which provider to use
+	providerName := cfg.GetProvider()
+	if *providerFlag != "" {
+		if !config.IsValidProvider(*providerFlag) {
+			fail(format, "Unknown provider", output.BadInput(fmt.Errorf("%s (available: %s)", *providerFlag, strings.Join(config.GetAvailableProviders(), ", "))))
+		}
+		providerName = *providerFlag
+	}
+
+	// Check if API key is configured. Local servers and custom endpoints
+	// may not need one.
+	if cfg.GetAPIKey(providerName) == "" && config.RequiresAPIKey(providerName, resolveBaseURL(cfg, providerName, *baseURLFlag)) {
+		// Scripts cannot answer the setup questions
+		if format != output.FormatText {
+			fail(format, "Missing API key", output.WithCode(output.ExitAuth, fmt.Errorf("no API key configured for %s (run 'ask --setup')", providerName)))
+		}
 		fmt.Println("🤖 No configuration found. Starting setup process...")
 		fmt.Println()
 		if err := setup.Run(); err != nil {
@@ -210,6 +407,9 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to load configuration after setup: %v", err)
 		}
+		if *providerFlag == "" {
+			providerName = cfg.GetProvider()
+		}
 	}
 
 	// Ensure we have a current context (creates default if needed)
@@ -221,28 +421,612 @@ func main() {
 		}
 	}
 
-	// Get prompt from command line arguments
-	args := flag.Args()
-	if len(args) == 0 {
+	// Determine which model to use. The configured model belongs to the
+	// configured provider, so fall back to the default when overriding it.
+	model := cfg.Model
+	if providerName != cfg.GetProvider() {
+		model = config.DefaultModel(providerName)
+	}
+	if *modelFlag != "" {
+		model = *modelFlag
+	}
+
+	// Stream by default when writing to a terminal
+	stream := isTerminal(os.Stdout)
+	if *streamFlag {
+		stream = true
+	}
+	if *noStreamFlag {
+		stream = false
+	}
+
+	// Render Markdown only for a color terminal
+	markdown := isTerminal(os.Stdout) && !*rawFlag && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+
+	// JSON is written once the answer is complete, JSON lines as it streams
+	switch format {
+	case output.FormatJSON:
+		stream, markdown = false, false
+	case output.FormatJSONL:
+		stream, markdown = true, false
+	}
+
+	// Code is printed as it is, once the whole answer is known
+	codeOnly := *codeFlag || *codeLangFlag != "" || *codeIndexFlag != 0
+	if codeOnly {
+		stream, markdown = false, false
+	}
+	if *codeIndexFlag < 0 {
+		fail(format, "Invalid flags", output.BadInput(fmt.Errorf("--code-index counts from 1")))
+	}
+	if format != output.FormatText && (codeOnly || *saveCodeFlag != "" || *copyFlag) {
+		fail(format, "Invalid flags", output.BadInput(fmt.Errorf("--code, --save-code and --copy cannot be used with --output %s", format)))
+	}
+
+	timeouts, err := resolveTimeouts(cfg, *timeoutFlag)
+	if err != nil {
+		fail(format, "Invalid timeout", output.BadInput(err))
+	}
+
+	if *personaFlag != "" && !persona.Exists(*personaFlag) {
+		fail(format, "Persona not found", output.BadInput(fmt.Errorf("%s (see 'ask persona list')", *personaFlag)))
+	}
+
+	// Tools are offered when asked for or enabled in the configuration.
+	// Calls needing confirmation are refused when no one can answer.
+	var toolRunner *tools.Runner
+	useTools := *toolsFlag || (cfg.Tools != nil && cfg.Tools.Enabled)
+	if useTools && providerName != config.ProviderOpenAI {
+		// Only --tools is an error; tools enabled in the configuration are
+		// left out for other providers
+		if *toolsFlag {
+			fail(format, "Invalid flags", output.BadInput(fmt.Errorf("tools are only supported by the %s provider", config.ProviderOpenAI)))
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Tools are only supported by the %s provider; asking without them.\n", config.ProviderOpenAI)
+		useTools = false
+	}
+	if useTools {
+		if *toolsFlag && !config.LookupModel(model).Tools {
+			fail(format, "Invalid flags", output.BadInput(fmt.Errorf("%s cannot call tools", model)))
+		}
+		var confirm tools.Confirm
+		if isTerminal(os.Stdin) {
+			confirm = func(prompt string) string {
+				answer, err := repl.EditLine(os.Stdin, os.Stderr, true, prompt, "")
+				if err != nil {
+					return ""
+				}
+				return answer
+			}
+		}
+		toolRunner, err = tools.New(cfg, confirm)
+		if err != nil {
+			fail(format, "Invalid configuration", output.BadInput(err))
+		}
+		toolRunner.Notify = func(message string) {
+			fmt.Fprintln(os.Stderr, message)
+		}
+
+		// The tools of the configured MCP servers are offered alongside.
+		// Servers that fail are reported and left out.
+		clients, errs := mcp.StartAll(context.Background(), cfg.MCPServers)
+		for _, client := range clients {
+			if err := toolRunner.AddMCP(context.Background(), client, cfg.MCPServers[client.Name].Policy); err != nil {
+				errs = append(errs, err)
+				client.Close()
+			}
+		}
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+	}
+
+	sess := &session{
+		cfg:           cfg,
+		providerName:  providerName,
+		baseURL:       resolveBaseURL(cfg, providerName, *baseURLFlag),
+		model:         model,
+		modelOverride: *modelFlag != "",
+		system:        *systemFlag,
+		persona:       *personaFlag,
+		stream:        stream,
+		markdown:      markdown,
+		output:        format,
+		code: codeOptions{
+			only:    codeOnly,
+			lang:    *codeLangFlag,
+			index:   *codeIndexFlag,
+			saveDir: *saveCodeFlag,
+			copy:    *copyFlag,
+		},
+		noContext: *noContextFlag,
+		tools:     toolRunner,
+		timeouts:  timeouts,
+		sampling:  samplingFlags,
+	}
+
+	// Ctrl-C and SIGTERM cancel the request; a second Ctrl-C exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if *compactFlag {
+		if err := sess.compactCurrent(ctx); err != nil {
+			log.Fatalf("Failed to compact context: %v", err)
+		}
+		return
+	}
+
+	// Shell command suggestions: ask cmd "what to do"
+	if flag.NArg() >= 1 && flag.Arg(0) == "cmd" {
+		if format != output.FormatText {
+			fail(format, "Invalid flags", output.BadInput(fmt.Errorf("--output %s cannot be used with 'ask cmd'", format)))
+		}
+		if flag.NArg() < 2 {
+			fmt.Println("❌ No task provided.")
+			fmt.Println("Usage: ask cmd \"what you want to do\"")
+			os.Exit(output.ExitBadInput)
+		}
+		if err := sess.suggestCommand(ctx, strings.Join(flag.Args()[1:], " ")); err != nil {
+			fail(format, "Command suggestion failed", err)
+		}
+		return
+	}
+
+	if *interactiveFlag || (flag.NArg() == 1 && flag.Arg(0) == "chat") {
+		if format != output.FormatText {
+			fail(format, "Invalid flags", output.BadInput(fmt.Errorf("--output %s cannot be used with an interactive chat", format)))
+		}
+		// The chat cancels requests on Ctrl-C itself
+		stop()
+		err := runChat(sess)
+		sess.close()
+		if err != nil {
+			log.Fatalf("Chat failed: %v", err)
+		}
+		return
+	}
+
+	// Get prompt from command line arguments, plus stdin when it is piped
+	// or explicitly requested with "-"
+	var args []string
+	readStdin := !isTerminal(os.Stdin)
+	for _, arg := range flag.Args() {
+		if arg == input.StdinMarker {
+			readStdin = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	var piped string
+	if readStdin {
+		piped, err = input.ReadStdin(os.Stdin, cfg.GetMaxInputBytes())
+		if err != nil {
+			fail(format, "Failed to read input", output.BadInput(err))
+		}
+	}
+	var attachments []input.Attachment
+	if len(fileFlags) > 0 {
+		attachments, err = input.LoadFiles(fileFlags, cfg.GetMaxInputBytes())
+		if err != nil {
+			fail(format, "Failed to attach files", output.BadInput(err))
+		}
+	}
+	var prompt string
+	if *templateFlag != "" {
+		prompt, err = renderTemplate(*templateFlag, varFlags, strings.Join(args, " "), piped, attachments, cfg.GetMaxInputBytes())
+		if err != nil {
+			fail(format, "Failed to render template", output.BadInput(err))
+		}
+	} else {
+		prompt = input.Compose(strings.Join(args, " "), piped, attachments)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		if format != output.FormatText {
+			fail(format, "No prompt provided", output.BadInput(fmt.Errorf("no prompt provided")))
+		}
 		fmt.Println("❌ No prompt provided.")
 		fmt.Println("Usage: ask \"your question here\"")
+		fmt.Println("       command | ask \"your question here\"")
 		fmt.Println("For help: ask --help")
-		os.Exit(1)
+		os.Exit(output.ExitBadInput)
 	}
-	prompt := strings.Join(args, " ")
 
-	// Determine which model to use
-	model := cfg.Model
-	if *modelFlag != "" {
-		model = *modelFlag
+	err = sess.ask(ctx, prompt)
+	sess.close()
+	if err != nil {
+		if format != output.FormatText {
+			// The error is already part of the printed result
+			os.Exit(output.ExitCode(err))
+		}
+		fail(format, "Request failed", err)
+	}
+}
+
+// fail reports err, as a JSON result unless the output format is text, and
+// exits with the exit code for the kind of error
+func fail(format, prefix string, err error) {
+	if format != output.FormatText {
+		output.WriteResult(os.Stdout, format, &output.Result{Error: output.NewError(err)})
+	} else {
+		log.Printf("%s: %v", prefix, err)
+	}
+	os.Exit(output.ExitCode(err))
+}
+
+// renderTemplate builds the prompt from a template. Besides the --var
+// assignments, piped text is bound to "input", attached files to "files"
+// and the prompt arguments to "args", unless --var sets them.
+func renderTemplate(name string, assignments []string, args, piped string, attachments []input.Attachment, limit int64) (string, error) {
+	source, err := templates.Load(name)
+	if err != nil {
+		return "", err
+	}
+	t, err := templates.Parse(name, source, limit)
+	if err != nil {
+		return "", err
+	}
+	vars, err := templates.ParseVars(assignments, limit)
+	if err != nil {
+		return "", err
+	}
+
+	bind := func(key, value string) {
+		if _, ok := vars[key]; !ok && strings.TrimSpace(value) != "" {
+			vars[key] = value
+		}
+	}
+	bind("input", strings.TrimRight(piped, "\n"))
+	var files []string
+	for _, file := range attachments {
+		files = append(files, input.FormatAttachment(file))
+	}
+	bind("files", strings.Join(files, "\n\n"))
+	bind("args", args)
+
+	return t.Render(vars)
+}
+
+// session holds the settings shared by every request of a single run, so
+// that interactive chats can reuse one loaded configuration
+type session struct {
+	cfg           *config.Config
+	providerName  string
+	baseURL       string
+	model         string
+	modelOverride bool   // model was chosen explicitly and wins over the persona's
+	system        string // one-off system prompt overriding the configured ones
+	persona       string // persona overriding the current context's one
+	stream        bool
+	markdown      bool   // render the answer for the terminal
+	output        string // output format, see the output package
+	code          codeOptions
+	noContext     bool
+	tools         *tools.Runner   // nil when the model may not call tools
+	sampling      config.Sampling // sampling options given as flags
+	timeouts      provider.Timeouts
+	warned        map[string]bool // missing personas already reported
+}
+
+// close stops the MCP servers started for this run
+func (s *session) close() {
+	if s.tools != nil {
+		s.tools.Close()
+	}
+}
+
+// currentPersona returns the persona for the next request: the one chosen
+// for this run, else the one attached to the current context
+func (s *session) currentPersona() (*persona.Persona, error) {
+	if s.persona != "" {
+		return persona.Load(s.persona)
+	}
+	current := s.cfg.GetCurrentContext()
+	if current == nil || current.Persona == "" {
+		return nil, nil
+	}
+	// A persona deleted meanwhile is left out rather than failing every request
+	if !persona.Exists(current.Persona) {
+		if s.warned[current.Persona] {
+			return nil, nil
+		}
+		if s.warned == nil {
+			s.warned = make(map[string]bool)
+		}
+		s.warned[current.Persona] = true
+		fmt.Fprintf(os.Stderr, "⚠️  Persona %s of this context no longer exists; asking without it (detach it with '/persona none' in ask chat).\n", current.Persona)
+		return nil, nil
+	}
+	return persona.Load(current.Persona)
+}
+
+// modelFor returns the model to ask with persona p: the persona's unless
+// one was chosen explicitly
+func (s *session) modelFor(p *persona.Persona) string {
+	if p != nil && p.Model != "" && !s.modelOverride {
+		return p.Model
+	}
+	return s.model
+}
+
+// ask sends prompt along with the current context's history, prints the
+// answer and records the exchange in the current context
+func (s *session) ask(ctx context.Context, prompt string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	req, err := s.prepare(ctx, prompt, "")
+	if err != nil {
+		return err
+	}
+	model := req.Model
+
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	// Rendered answers are written through the Markdown renderer, which
+	// prints each line once it is complete
+	var out io.Writer = os.Stdout
+	var md *render.Writer
+	if s.markdown {
+		md = render.New(os.Stdout)
+		out = md
+	}
+
+	var onDelta func(string)
+	if s.stream {
+		onDelta = func(text string) {
+			if s.output == output.FormatJSONL {
+				output.WriteDelta(os.Stdout, text)
+				return
+			}
+			fmt.Fprint(out, text)
+		}
+	}
+
+	// Make API request
+	start := time.Now()
+	resp, exchange, err := s.chat(ctx, client, req, onDelta)
+
+	// An interrupted answer is kept, marked as truncated, so that the
+	// conversation can go on from it
+	truncated := false
+	if err != nil && ctx.Err() != nil {
+		err = s.interruption(ctx)
+		if resp != nil && resp.Content != "" && !s.noContext {
+			truncated = true
+			s.record(prompt, exchange, resp.Content+"\n\n"+config.TruncatedMarker)
+			if s.output == output.FormatText {
+				defer fmt.Fprintln(os.Stderr, "💾 The partial answer was saved to the context, marked as truncated.")
+			}
+		}
+	}
+
+	if s.output != output.FormatText {
+		// The result is reported even when the request failed
+		result := &output.Result{
+			Model:     model,
+			Provider:  s.providerName,
+			LatencyMS: time.Since(start).Milliseconds(),
+		}
+		if current := s.cfg.GetCurrentContext(); current != nil && !s.noContext {
+			result.ContextID = current.ID
+		}
+		if resp != nil {
+			result.Answer = resp.Content
+			result.FinishReason = resp.FinishReason
+			result.Usage = resp.Usage
+			result.Truncated = truncated
+		}
+		if err != nil {
+			result.Error = output.NewError(err)
+		}
+		if writeErr := output.WriteResult(os.Stdout, s.output, result); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		if s.stream {
+			if md != nil {
+				md.Flush()
+			} else {
+				fmt.Println()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !s.stream && !s.code.only {
+			fmt.Fprintln(out, resp.Content)
+			if md != nil {
+				md.Flush()
+			}
+		}
+	}
+
+	if resp.Content == "" {
+		if s.output == output.FormatText {
+			fmt.Println("No response from the model.")
+		}
+		return nil
+	}
+
+	// Save conversation history if not disabled
+	if !s.noContext {
+		s.record(prompt, exchange, resp.Content)
+	}
+	s.warnBudget()
+	return s.code.handle(resp.Content)
+}
+
+// warnBudget warns on stderr when the monthly budget is nearly spent
+func (s *session) warnBudget() {
+	if s.cfg.Usage == nil || s.cfg.Usage.MonthlyBudget <= 0 {
+		return
+	}
+	warning, err := usage.BudgetWarning(s.cfg.Usage.MonthlyBudget, usage.LoadPrices(s.cfg), time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to check the budget: %v", err)
+	} else if warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+}
+
+// record saves an exchange to the current context: the prompt, any tool
+// calls and their results, and the answer
+func (s *session) record(prompt string, exchange []config.ChatMessage, answer string) {
+	s.cfg.AddToCurrentContext("user", prompt)
+	s.cfg.AppendToCurrentContext(exchange...)
+	s.cfg.AddToCurrentContext("assistant", answer)
+	if err := config.Save(s.cfg); err != nil {
+		log.Printf("Warning: Failed to save conversation history: %v", err)
+	}
+}
+
+// withTimeout applies the total request timeout to ctx
+func (s *session) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeouts.Total <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeouts.Total)
+}
+
+// interruption describes why ctx ended a request
+func (s *session) interruption(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output.WithCode(output.ExitTimeout, fmt.Errorf("request timed out after %s", s.timeouts.Total))
+	}
+	return output.WithCode(output.ExitInterrupted, fmt.Errorf("request cancelled"))
+}
+
+// chat sends req and, while the model asks for tools, runs them and sends
+// their results back. It returns the final answer with the usage of every
+// round, and the tool calls and results exchanged on the way.
+func (s *session) chat(ctx context.Context, client provider.Provider, req *provider.Request, onDelta func(string)) (*provider.Response, []config.ChatMessage, error) {
+	if s.tools == nil {
+		resp, err := client.Chat(ctx, req, onDelta)
+		return resp, nil, err
+	}
+	if !config.LookupModel(req.Model).Tools {
+		fmt.Fprintf(os.Stderr, "⚠️  %s cannot call tools; asking without them.\n", req.Model)
+		resp, err := client.Chat(ctx, req, onDelta)
+		return resp, nil, err
+	}
+
+	req.Tools = s.tools.Schemas()
+	var exchange []config.ChatMessage
+	var usage *provider.Usage
+	for round := 1; ; round++ {
+		// Past the limit the model has to answer with what it has
+		if round > tools.MaxRounds {
+			req.Tools = nil
+		}
+		resp, err := client.Chat(ctx, req, onDelta)
+		if resp != nil {
+			usage = addUsage(usage, resp.Usage)
+		}
+		if err != nil || len(resp.ToolCalls) == 0 || req.Tools == nil {
+			if resp != nil {
+				resp.Usage = usage
+				resp.ToolCalls = nil
+			}
+			return resp, exchange, err
+		}
+
+		call := config.ChatMessage{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls}
+		exchange = append(exchange, call)
+		req.Messages = append(req.Messages, call)
+		for _, toolCall := range resp.ToolCalls {
+			result := s.tools.Call(ctx, toolCall)
+			exchange = append(exchange, result)
+			req.Messages = append(req.Messages, result)
+		}
+	}
+}
+
+// addUsage adds the usage of another request to total
+func addUsage(total, usage *provider.Usage) *provider.Usage {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		return &provider.Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens, TotalTokens: usage.TotalTokens}
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	return total
+}
+
+// prepare builds the request for prompt: the persona's settings, the system
+// prompt and the current context's history. instructions, if given, are
+// put before the system prompt.
+func (s *session) prepare(ctx context.Context, prompt, instructions string) (*provider.Request, error) {
+	p, err := s.currentPersona()
+	if err != nil {
+		return nil, err
+	}
+	// Sampling options: the global settings, then the persona's
+	// temperature, then the context settings, then flags
+	model := s.modelFor(p)
+	var personaOptions *config.Sampling
+	if p != nil {
+		personaOptions = &config.Sampling{Temperature: p.Temperature}
+	}
+	options := s.cfg.GetSampling(personaOptions)
+
+	// Options given as flags that the model does not accept are rejected;
+	// stored defaults are left out instead
+	info := config.LookupModel(model)
+	if _, rejected := sampling.Adapt(s.sampling, s.providerName, info); len(rejected) > 0 {
+		return nil, output.BadInput(fmt.Errorf("%s does not accept %s", model, strings.Join(rejected, ", ")))
+	}
+	options, adapted := sampling.Adapt(options.Merge(&s.sampling), s.providerName, info)
+	if len(adapted) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  %s does not accept %s; adjusted the request.\n", model, strings.Join(adapted, ", "))
+	}
+
+	// Summarize older turns once the history grows too large
+	if !s.noContext && compact.Needed(model, s.cfg.GetCurrentContextHistory(), compact.Threshold(s.cfg, model)) {
+		fmt.Fprintln(os.Stderr, "🗜️  Compacting conversation history...")
+		if err := s.compact(ctx, model); err != nil {
+			log.Printf("Warning: Failed to compact conversation history: %v", err)
+		}
 	}
 
 	// Prepare messages for API request
 	var messages []config.ChatMessage
 
+	// The system prompt is added per request rather than stored as a turn
+	system := s.system
+	if system == "" && p != nil {
+		system = p.Instructions()
+	}
+	if system == "" {
+		system = s.cfg.GetSystemPrompt()
+	}
+	if instructions != "" {
+		system = strings.TrimSpace(instructions + "\n\n" + system)
+	}
+	if system != "" {
+		messages = append(messages, config.ChatMessage{
+			Role:    "system",
+			Content: system,
+		})
+	}
+
 	// Add conversation history if not disabled
-	if !*noContextFlag {
-		messages = append(messages, cfg.GetCurrentContextHistory()...)
+	if !s.noContext {
+		if current := s.cfg.GetCurrentContext(); current != nil && current.Summary != "" {
+			messages = append(messages, compact.SummaryMessage(current.Summary))
+		}
+		messages = append(messages, s.cfg.GetCurrentContextHistory()...)
 	}
 
 	// Add current user message
@@ -251,71 +1035,617 @@ func main() {
 		Content: prompt,
 	})
 
-	// Make API request
-	chatReq := ChatRequest{
+	// Drop the oldest turns if the history no longer fits the model
+	budget := tokens.Budget(model, s.cfg.MaxContextTokens)
+	messages, dropped := tokens.Fit(model, messages, budget)
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "✂️  Dropped the %d oldest turn(s) of the conversation to fit the %d token budget.\n", dropped, budget)
+	}
+
+	return &provider.Request{
 		Model:    model,
 		Messages: messages,
+		Sampling: options,
+	}, nil
+}
+
+// client returns the provider for this session
+func (s *session) client() (provider.Provider, error) {
+	client, err := provider.New(s.providerName, provider.Settings{
+		APIKey:   s.cfg.GetAPIKey(s.providerName),
+		BaseURL:  s.baseURL,
+		Timeouts: s.timeouts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage is recorded for each attempt that returns an answer
+	tracker := &usage.Tracker{
+		Provider: client,
+		Warn: func(err error) {
+			log.Printf("Warning: Failed to record usage: %v", err)
+		},
+	}
+	if current := s.cfg.GetCurrentContext(); current != nil && !s.noContext {
+		tracker.ContextID, tracker.ContextName = current.ID, current.Name
+	}
+
+	policy := provider.DefaultRetryPolicy
+	if retry := s.cfg.Retry; retry != nil {
+		if retry.MaxAttempts > 0 {
+			policy.MaxAttempts = retry.MaxAttempts
+		}
+		if retry.DeadlineSeconds > 0 {
+			policy.Deadline = time.Duration(retry.DeadlineSeconds) * time.Second
+		}
+	}
+	return provider.WithRetry(tracker, policy, func(r provider.Retry) {
+		fmt.Fprintf(os.Stderr, "⏳ %v; retrying in %s (attempt %d of %d)\n", r.Err, r.Delay.Round(100*time.Millisecond), r.Attempt+1, r.MaxAttempts)
+	}), nil
+}
+
+// suggestCommand asks the model for a shell command that does what request
+// describes and offers to run, edit or copy it. The exchange, including
+// the output of a command that was run, is recorded in the current context.
+func (s *session) suggestCommand(ctx context.Context, request string) error {
+	env := shell.Detect()
+	req, err := s.prepare(ctx, request, shell.Instructions(env))
+	if err != nil {
+		return err
+	}
+	client, err := s.client()
+	if err != nil {
+		return err
 	}
-	body, err := json.Marshal(chatReq)
+	chatCtx, cancel := s.withTimeout(ctx)
+	resp, err := client.Chat(chatCtx, req, nil)
+	cancel()
 	if err != nil {
-		log.Fatalf("Failed to marshal request: %v", err)
+		if chatCtx.Err() != nil {
+			return s.interruption(chatCtx)
+		}
+		return err
+	}
+
+	command, explanation := shell.Parse(resp.Content)
+	if command == "" {
+		fmt.Println(resp.Content)
+		return fmt.Errorf("the model did not suggest a command")
+	}
+
+	// Without a terminal there is no one to confirm, so only print it
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		fmt.Println(command)
+		return s.recordCommand(request, resp.Content, "")
+	}
+
+	if explanation != "" {
+		fmt.Printf("💡 %s\n", explanation)
+	}
+	record := ""
+	for {
+		fmt.Printf("\n  \x1b[1m%s\x1b[0m\n\n", command)
+		choice, err := repl.EditLine(os.Stdin, os.Stdout, true, "Run, edit, copy or cancel? [r/e/c/N] ", "")
+		if err != nil {
+			choice = ""
+		}
+
+		switch strings.ToLower(strings.TrimSpace(choice)) {
+		case "r", "run", "y", "yes":
+			status, out, err := shell.Run(env, command)
+			if err != nil {
+				return err
+			}
+			if status == 0 {
+				fmt.Println("✅ Exit status 0")
+			} else {
+				fmt.Printf("❌ Exit status %d\n", status)
+			}
+			record = fmt.Sprintf("Ran the command:\n%s\nExit status: %d\nOutput:\n%s", input.Fence(command, env.Shell), status, input.Fence(out, ""))
+		case "e", "edit":
+			edited, err := repl.EditLine(os.Stdin, os.Stdout, true, "$ ", command)
+			if err == nil && strings.TrimSpace(edited) != "" {
+				command = strings.TrimSpace(edited)
+			}
+			continue
+		case "c", "copy":
+			if err := clipboard.Copy(command); err != nil {
+				return fmt.Errorf("failed to copy to the clipboard: %v", err)
+			}
+			fmt.Println("📋 Copied to the clipboard.")
+		default:
+			fmt.Println("Cancelled.")
+		}
+		break
+	}
+	return s.recordCommand(request, resp.Content, record)
+}
+
+// recordCommand adds a command suggestion to the current context. The record
+// of running the command is kept with the answer so that the history still
+// alternates between the user and the assistant.
+func (s *session) recordCommand(request, answer, record string) error {
+	if s.noContext {
+		return nil
 	}
+	if record != "" {
+		answer += "\n\n" + record
+	}
+	s.cfg.AddToCurrentContext("user", request)
+	s.cfg.AddToCurrentContext("assistant", answer)
+	if err := config.Save(s.cfg); err != nil {
+		log.Printf("Warning: Failed to save conversation history: %v", err)
+	}
+	return nil
+}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(body))
+// codeOptions selects what to do with the code blocks of an answer
+type codeOptions struct {
+	only    bool   // print the code instead of the answer
+	lang    string // only use blocks in this language
+	index   int    // only use this block, counting from 1
+	saveDir string // save the blocks to files in this directory
+	copy    bool   // copy the answer, or the code, to the clipboard
+}
+
+// handle prints, saves or copies the code blocks of answer
+func (o codeOptions) handle(answer string) error {
+	if !o.only && o.saveDir == "" && !o.copy {
+		return nil
+	}
+
+	blocks, selectErr := code.Select(code.Extract(answer), o.lang, o.index)
+	result := answer
+	if o.only {
+		if selectErr != nil {
+			// Never print prose where a script expects code
+			fmt.Fprintln(os.Stderr, answer)
+			return selectErr
+		}
+		result = code.Join(blocks)
+		fmt.Println(result)
+	}
+
+	if o.saveDir != "" {
+		if selectErr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Nothing saved: %v\n", selectErr)
+		} else {
+			paths, err := code.Save(o.saveDir, blocks)
+			for _, path := range paths {
+				fmt.Fprintf(os.Stderr, "💾 Saved %s\n", path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if o.copy {
+		if err := clipboard.Copy(result); err != nil {
+			return fmt.Errorf("failed to copy to the clipboard: %v", err)
+		}
+		fmt.Fprintln(os.Stderr, "📋 Copied to the clipboard.")
+	}
+	return nil
+}
+
+// compactCurrent compacts the current context with the model its requests
+// use
+func (s *session) compactCurrent(ctx context.Context) error {
+	p, err := s.currentPersona()
 	if err != nil {
-		log.Fatalf("Failed to create request: %v", err)
+		return err
+	}
+	return s.compact(ctx, s.modelFor(p))
+}
+
+// compact summarizes all but the most recent turns of the current context
+// with model and archives the summarized turns
+func (s *session) compact(ctx context.Context, model string) error {
+	current := s.cfg.GetCurrentContext()
+	if current == nil {
+		return fmt.Errorf("no current context")
+	}
+	older, _ := compact.Split(current.History, compact.KeepTurns)
+	if len(older) == 0 {
+		fmt.Fprintf(os.Stderr, "🗜️  Nothing to compact: the context has %d turns or fewer.\n", compact.KeepTurns)
+		return nil
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	client, err := s.client()
 	if err != nil {
-		log.Fatalf("Request failed: %v", err)
+		return err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	resp, err := client.Chat(ctx, &provider.Request{
+		Model:    model,
+		Messages: compact.Request(current.Summary, older),
+	}, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return s.interruption(ctx)
+		}
+		return err
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return fmt.Errorf("the model returned an empty summary")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(resp.Body)
-		log.Fatalf("OpenAI API error: %s", string(b))
+	if err := s.cfg.CompactCurrentContext(strings.TrimSpace(resp.Content), len(older)); err != nil {
+		return err
+	}
+	if err := config.Save(s.cfg); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
 	}
+	fmt.Fprintf(os.Stderr, "🗜️  Summarized %d messages of '%s'; they are archived and can be restored with --restore-history.\n", len(older), current.Name)
+	return nil
+}
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		log.Fatalf("Failed to decode response: %v", err)
+// runChat starts an interactive chat that keeps the configuration loaded
+// between turns
+func runChat(s *session) error {
+	cfg := s.cfg
+	save := func() error {
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("failed to save configuration: %v", err)
+		}
+		return nil
 	}
 
-	if len(chatResp.Choices) > 0 {
-		response := chatResp.Choices[0].Message.Content
-		fmt.Println(response)
+	commands := []repl.Command{
+		{
+			Name:  "switch",
+			Usage: "/switch <context>",
+			Help:  "Switch to context by ID or name",
+			Run: func(args string) error {
+				if args == "" {
+					return fmt.Errorf("usage: /switch <context>")
+				}
+				if err := switchToContext(cfg, args); err != nil {
+					return err
+				}
+				return save()
+			},
+		},
+		{
+			Name:  "new",
+			Usage: "/new <name>",
+			Help:  "Create a new context and switch to it",
+			Run: func(args string) error {
+				if args == "" {
+					return fmt.Errorf("usage: /new <name>")
+				}
+				id, err := cfg.CreateNewContext(args)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("✅ Created new context '%s' with ID: %s\n", args, id)
+				return save()
+			},
+		},
+		{
+			Name: "clear",
+			Help: "Clear the current context's history",
+			Run: func(args string) error {
+				cfg.ClearCurrentContext()
+				fmt.Println("🗑️  Conversation history cleared.")
+				return save()
+			},
+		},
+		{
+			Name:  "system",
+			Usage: "/system [prompt|clear]",
+			Help:  "Show, set or clear the current context's system prompt",
+			Run: func(args string) error {
+				switch args {
+				case "":
+					printSystemPrompts(cfg)
+					return nil
+				case "clear":
+					args = ""
+				}
+				if err := cfg.SetCurrentContextSystemPrompt(args); err != nil {
+					return err
+				}
+				fmt.Println("✅ System prompt updated.")
+				return save()
+			},
+		},
+		{
+			Name:  "persona",
+			Usage: "/persona [name|none]",
+			Help:  "Show the persona or attach one to the current context",
+			Run: func(args string) error {
+				switch args {
+				case "":
+					if p, err := s.currentPersona(); err != nil {
+						return err
+					} else if p == nil {
+						fmt.Println("🎭 No persona.")
+					} else {
+						fmt.Printf("🎭 Persona: %s\n", p.Name)
+					}
+					return nil
+				case "none":
+					args = ""
+				default:
+					if !persona.Exists(args) {
+						return fmt.Errorf("persona not found: %s", args)
+					}
+				}
+				s.persona = ""
+				if err := cfg.SetCurrentContextPersona(args); err != nil {
+					return err
+				}
+				fmt.Println("✅ Persona updated.")
+				return save()
+			},
+		},
+		{
+			Name: "compact",
+			Help: "Summarize the older turns of the current context",
+			Run: func(args string) error {
+				return s.compactCurrent(context.Background())
+			},
+		},
+		{
+			Name:  "model",
+			Usage: "/model [name]",
+			Help:  "Show or change the model for this chat",
+			Run: func(args string) error {
+				if args != "" {
+					s.model = args
+					s.modelOverride = true
+				}
+				fmt.Printf("🤖 Model: %s\n", s.model)
+				return nil
+			},
+		},
+		{
+			Name: "list",
+			Help: "List all contexts",
+			Run: func(args string) error {
+				listContexts(cfg)
+				return nil
+			},
+		},
+		{
+			Name:  "delete",
+			Usage: "/delete <context>",
+			Help:  "Delete context by ID or name",
+			Run: func(args string) error {
+				if args == "" {
+					return fmt.Errorf("usage: /delete <context>")
+				}
+				if err := deleteContext(cfg, args); err != nil {
+					return err
+				}
+				return save()
+			},
+		},
+	}
 
-		// Save conversation history if not disabled
-		if !*noContextFlag {
-			cfg.AddToCurrentContext("user", prompt)
-			cfg.AddToCurrentContext("assistant", response)
-			if err := config.Save(cfg); err != nil {
-				log.Printf("Warning: Failed to save conversation history: %v", err)
+	return repl.Run(repl.Options{
+		Prompt: func() string {
+			if current := cfg.GetCurrentContext(); current != nil {
+				return current.Name + "> "
 			}
+			return "> "
+		},
+		Ask:      s.ask,
+		Commands: commands,
+		In:       os.Stdin,
+		Out:      os.Stdout,
+		Terminal: isTerminal(os.Stdin),
+	})
+}
+
+// isSystemAction reports whether arg is an action of the system subcommand
+func isSystemAction(arg string) bool {
+	return arg == "show" || arg == "set" || arg == "clear"
+}
+
+// runSystemCommand shows, sets or clears the system prompt of the current
+// context, or the global one with --global
+func runSystemCommand(args []string) error {
+	action := args[0]
+	fs := flag.NewFlagSet("system "+action, flag.ContinueOnError)
+	global := fs.Bool("global", false, "Apply to the global system prompt instead of the current context")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	prompt := strings.Join(fs.Args(), " ")
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	switch action {
+	case "show":
+		printSystemPrompts(cfg)
+		return nil
+	case "set":
+		if prompt == "" {
+			return fmt.Errorf("usage: ask system set [--global] \"prompt\"")
 		}
+	case "clear":
+		prompt = ""
+	}
+
+	if *global {
+		cfg.SystemPrompt = prompt
+	} else if err := cfg.SetCurrentContextSystemPrompt(prompt); err != nil {
+		return err
+	}
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
+	}
+
+	scope := "global"
+	if !*global {
+		scope = "context"
+	}
+	if prompt == "" {
+		fmt.Printf("🗑️  Cleared the %s system prompt.\n", scope)
 	} else {
-		fmt.Println("No response from ChatGPT.")
+		fmt.Printf("✅ Set the %s system prompt.\n", scope)
+	}
+	return nil
+}
+
+// printSystemPrompts shows the global and current context system prompts
+func printSystemPrompts(cfg *config.Config) {
+	global := cfg.SystemPrompt
+	if global == "" {
+		global = "(none)"
+	}
+	fmt.Printf("Global system prompt: %s\n", global)
+
+	if current := cfg.GetCurrentContext(); current != nil {
+		contextPrompt := current.SystemPrompt
+		if contextPrompt == "" {
+			contextPrompt = "(none, uses the global prompt)"
+		}
+		fmt.Printf("Context system prompt (%s): %s\n", current.Name, contextPrompt)
 	}
 }
 
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// resolveTimeouts returns the request timeouts: the defaults, then the
+// configuration, then the --timeout flag
+func resolveTimeouts(cfg *config.Config, flagValue string) (provider.Timeouts, error) {
+	timeouts := provider.DefaultTimeouts
+	if t := cfg.Timeouts; t != nil {
+		if t.ConnectSeconds > 0 {
+			timeouts.Connect = time.Duration(t.ConnectSeconds) * time.Second
+		}
+		if t.FirstByteSeconds > 0 {
+			timeouts.FirstByte = time.Duration(t.FirstByteSeconds) * time.Second
+		}
+		if t.TotalSeconds > 0 {
+			timeouts.Total = time.Duration(t.TotalSeconds) * time.Second
+		}
+	}
+	if flagValue == "" {
+		return timeouts, nil
+	}
+
+	// Either a single duration for the whole request, or a list of
+	// name=duration pairs. Plain numbers are seconds and 0 disables a limit.
+	for _, part := range strings.Split(flagValue, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			name, value = "total", name
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			seconds, numErr := strconv.ParseFloat(value, 64)
+			if numErr != nil || seconds < 0 {
+				return timeouts, fmt.Errorf("invalid duration %q (use e.g. 30s or 2m)", value)
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		switch name {
+		case "connect":
+			timeouts.Connect = d
+		case "first-byte", "first_byte":
+			timeouts.FirstByte = d
+		case "total":
+			timeouts.Total = d
+		default:
+			return timeouts, fmt.Errorf("unknown timeout %q (use connect, first-byte or total)", name)
+		}
+	}
+	return timeouts, nil
+}
+
+// resolveBaseURL returns the base URL for a provider, preferring the
+// --base-url flag, then the ASK_BASE_URL environment variable, then the
+// configuration
+func resolveBaseURL(cfg *config.Config, providerName, flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("ASK_BASE_URL"); env != "" {
+		return env
+	}
+	return cfg.GetBaseURL(providerName)
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 func showHelp() {
 	fmt.Println("🤖 Ask CLI - Get ChatGPT answers from the command line")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ask \"your question here\"")
 	fmt.Println("  ask --model gpt-4 \"your question here\"")
+	fmt.Println("  command | ask \"your question here\"")
+	fmt.Println("  ask \"your question here\" - < file.txt")
+	fmt.Println("  ask chat")
+	fmt.Println("  ask cmd \"find large files modified this week\"")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  --setup         Run the interactive setup process")
 	fmt.Println("  --model         Override the configured model for this request")
+	fmt.Println("  --provider      Override the configured provider (openai, anthropic, ollama)")
+	fmt.Println("  --base-url      Override the API base URL (also ASK_BASE_URL)")
 	fmt.Println("  --help          Show this help message")
 	fmt.Println("  --show-config   Show the current configuration")
 	fmt.Println("  --edit-config   Edit the current configuration")
 	fmt.Println("  --clear         Clear conversation history")
 	fmt.Println("  --no-context    Don't use conversation history for this request")
+	fmt.Println("  --system        Use this system prompt for this request only")
+	fmt.Println("  --persona       Use a saved persona (with --new-context: attach it)")
+	fmt.Println("  --compact       Summarize the older turns of the current context")
+	fmt.Println("  --restore-history Restore the turns archived by --compact")
+	fmt.Println("  --stream        Print the answer as it is generated (default on a terminal)")
+	fmt.Println("  --no-stream     Wait for the complete answer before printing it")
+	fmt.Println("  --raw           Print the answer as plain Markdown (also NO_COLOR)")
+	fmt.Println("  --output        Output format: text (default), json or jsonl")
+	fmt.Println("  --timeout       Request timeout: 2m, or connect=5s,first-byte=30s,total=2m")
+	fmt.Println("  --tools         Let the model read files, list directories, run allowed commands and use MCP servers")
+	fmt.Println()
+	fmt.Println("Sampling:")
+	fmt.Println("  --temperature   Sampling temperature, from 0 to 2")
+	fmt.Println("  --top-p         Nucleus sampling probability, from 0 to 1")
+	fmt.Println("  --max-tokens    Longest answer in tokens")
+	fmt.Println("  --presence-penalty, --frequency-penalty  Penalize repetition, from -2 to 2")
+	fmt.Println("  --seed          Sample deterministically where the model supports it")
+	fmt.Println("  --stop          End the answer at this text (repeatable)")
+	fmt.Println("  --reasoning-effort  minimal, low, medium or high, for reasoning models")
+	fmt.Println()
+	fmt.Println("Code Blocks:")
+	fmt.Println("  --code          Print only the code blocks of the answer")
+	fmt.Println("  --code-lang     Only use code blocks in this language, e.g. bash")
+	fmt.Println("  --code-index    Only use the Nth code block (counting from 1)")
+	fmt.Println("  --save-code     Save each code block to a file in this directory")
+	fmt.Println("  --copy          Copy the answer (or the code with --code) to the clipboard")
+	fmt.Println("  --file, -f      Attach a file, glob or directory (repeatable)")
+	fmt.Println("  --interactive   Start an interactive chat (same as 'ask chat')")
+	fmt.Println("  --template      Build the prompt from a template in ~/.ask/templates")
+	fmt.Println("  --var           Set a template variable, key=value or key=@file (repeatable)")
 	fmt.Println()
 	fmt.Println("Context Management:")
 	fmt.Println("  --new-context   Create a new context with the given name")
@@ -326,9 +1656,15 @@ func showHelp() {
 	fmt.Println("Examples:")
 	fmt.Println("  ask \"What is the capital of France?\"")
 	fmt.Println("  ask --model gpt-4 \"Explain quantum computing\"")
+	fmt.Println("  ask --provider anthropic \"Explain quantum computing\"")
+	fmt.Println("  ask --provider ollama --model llama3.2 \"Explain quantum computing\"")
+	fmt.Println("  ask --base-url http://localhost:8080/v1 \"Explain quantum computing\"")
 	fmt.Println("  ask --setup")
 	fmt.Println("  ask \"Continue from where we left off\"  # Uses conversation history")
 	fmt.Println("  ask --clear  # Clear conversation history")
+	fmt.Println("  git diff | ask \"Review this change\"")
+	fmt.Println("  ask -f main.go -f 'config/*.go' \"Where is the config loaded?\"")
+	fmt.Println("  ask --code-lang bash \"Script to rotate logs\" > rotate.sh")
 	fmt.Println()
 	fmt.Println("Context Examples:")
 	fmt.Println("  ask --new-context \"Python Project\"  # Create new context")
@@ -336,9 +1672,54 @@ func showHelp() {
 	fmt.Println("  ask --switch \"Python Project\"       # Switch to context")
 	fmt.Println("  ask \"What is a decorator?\"          # Use current context")
 	fmt.Println()
+	fmt.Println("System Prompts:")
+	fmt.Println("  ask system show                       # Show the global and context prompts")
+	fmt.Println("  ask system set \"Answer tersely\"       # Set the current context's prompt")
+	fmt.Println("  ask system set --global \"Our stack is Go + Postgres\"")
+	fmt.Println("  ask system clear [--global]           # Clear a prompt")
+	fmt.Println()
+	fmt.Println("Sampling Defaults:")
+	fmt.Println("  ask sampling show                     # Show the global and context options")
+	fmt.Println("  ask sampling set --temperature 0.2    # Set options for the current context")
+	fmt.Println("  ask sampling set --global --max-tokens 1000")
+	fmt.Println("  ask sampling clear [--global]         # Clear the options")
+	fmt.Println()
+	fmt.Println("Personas:")
+	fmt.Println("  ask persona list                      # List saved personas")
+	fmt.Println("  ask persona add reviewer --system \"You are a strict code reviewer\" [--model M] [--temperature T] [--format F]")
+	fmt.Println("  ask persona edit reviewer             # Edit interactively (or with the same flags)")
+	fmt.Println("  ask persona remove reviewer")
+	fmt.Println("  ask --persona reviewer \"Review this\" -f main.go")
+	fmt.Println("  ask --new-context \"PR 42\" --persona reviewer")
+	fmt.Println()
+	fmt.Println("Templates:")
+	fmt.Println("  ask template list                     # List templates and their variables")
+	fmt.Println("  ask template show explain             # Show a template")
+	fmt.Println("  go build 2>&1 | ask --template explain --var lang=Go")
+	fmt.Println()
+	fmt.Println("Models:")
+	fmt.Println("  ask models                            # Models of the current provider (cached for a day)")
+	fmt.Println("  ask models --provider anthropic --refresh")
+	fmt.Println()
+	fmt.Println("Usage and Costs:")
+	fmt.Println("  ask usage                             # This month by day, model and context")
+	fmt.Println("  ask usage --by model --month 2025-09  # One grouping, another month")
+	fmt.Println("  ask usage --days 7                    # The last 7 days (or --all)")
+	fmt.Println()
+	fmt.Println("MCP Servers:")
+	fmt.Println("  ask mcp list                          # List the configured servers")
+	fmt.Println("  ask mcp tools [server]                # List their tools (also resources, prompts)")
+	fmt.Println("  ask mcp read files file:///notes.md   # Print a resource")
+	fmt.Println("  ask --tools \"What changed in the issue tracker today?\"")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0 success, 1 other error, 2 bad input, 3 authentication failed,")
+	fmt.Println("  4 rate limited, 5 network error, 6 other API error, 7 timeout,")
+	fmt.Println("  130 interrupted")
+	fmt.Println()
 	fmt.Println("Configuration:")
 	fmt.Printf("  Config file: %s\n", config.GetConfigPath())
-	fmt.Println("  Run 'ask --setup' to configure your API key and preferred model")
+	fmt.Println("  Run 'ask --setup' to configure your provider, API key and preferred model")
 }
 
 func maskAPIKey(key string) string {
@@ -348,7 +1729,7 @@ func maskAPIKey(key string) string {
 	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
 }
 
-func editConfig() error {
+func editConfig(baseURLFlag string) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %v", err)
@@ -360,21 +1741,55 @@ func editConfig() error {
 
 	reader := bufio.NewReader(os.Stdin)
 
+	// Edit Provider
+	fmt.Printf("Current Provider: %s\n", cfg.GetProvider())
+	fmt.Printf("New Provider (%s, or press Enter to keep current): ", strings.Join(config.GetAvailableProviders(), ", "))
+	providerChoice, _ := reader.ReadString('\n')
+	providerChoice = strings.TrimSpace(providerChoice)
+	if providerChoice != "" && providerChoice != cfg.GetProvider() {
+		if !config.IsValidProvider(providerChoice) {
+			return fmt.Errorf("invalid provider: %s", providerChoice)
+		}
+		cfg.Provider = providerChoice
+		cfg.Model = config.DefaultModel(providerChoice)
+	}
+	providerName := cfg.GetProvider()
+
+	// Edit Base URL
+	fmt.Println()
+	currentBaseURL := cfg.GetBaseURL(providerName)
+	if currentBaseURL == "" {
+		currentBaseURL = "(provider default)"
+	}
+	fmt.Printf("Current Base URL: %s\n", currentBaseURL)
+	fmt.Print("New Base URL (\"default\" to reset, or press Enter to keep current): ")
+	baseURL, _ := reader.ReadString('\n')
+	baseURL = strings.TrimSpace(baseURL)
+	if baseURL == "default" {
+		cfg.SetBaseURL(providerName, "")
+	} else if baseURL != "" {
+		cfg.SetBaseURL(providerName, baseURL)
+	}
+
 	// Edit API Key
-	fmt.Printf("Current API Key: %s\n", maskAPIKey(cfg.APIKey))
+	fmt.Println()
+	fmt.Printf("Current API Key: %s\n", maskAPIKey(cfg.GetAPIKey(providerName)))
 	fmt.Print("New API Key (or press Enter to keep current): ")
 	apiKey, _ := reader.ReadString('\n')
 	apiKey = strings.TrimSpace(apiKey)
 	if apiKey != "" {
-		cfg.APIKey = apiKey
+		cfg.SetAPIKey(providerName, apiKey)
 	}
 
 	// Edit Model
 	fmt.Println()
 	fmt.Printf("Current Model: %s\n", cfg.Model)
 	fmt.Println("Available models:")
-	models := config.GetAvailableModels()
-	for i, model := range models {
+	available := models.Load(context.Background(), cfg, providerName, resolveBaseURL(cfg, providerName, baseURLFlag), models.Options{})
+	if available.Err != nil {
+		fmt.Printf("⚠️  Could not fetch the list of models (%v), showing the %s\n", available.Err, available.Describe())
+	}
+	for i, model := range available.Models {
 		fmt.Printf("  %d. %s\n", i+1, model)
 	}
 	fmt.Print("New Model (number or name, or press Enter to keep current): ")
@@ -382,28 +1797,11 @@ func editConfig() error {
 	modelChoice = strings.TrimSpace(modelChoice)
 
 	if modelChoice != "" {
-		// Try to parse as number first
-		var choice int
-		if _, err := fmt.Sscanf(modelChoice, "%d", &choice); err == nil {
-			if choice >= 1 && choice <= len(models) {
-				cfg.Model = models[choice-1]
-			} else {
-				return fmt.Errorf("invalid choice. Please select a number between 1 and %d", len(models))
-			}
-		} else {
-			// Check if it's a valid model name
-			validModel := false
-			for _, model := range models {
-				if model == modelChoice {
-					cfg.Model = modelChoice
-					validModel = true
-					break
-				}
-			}
-			if !validModel {
-				return fmt.Errorf("invalid model name: %s", modelChoice)
-			}
+		model, err := available.Choose(modelChoice)
+		if err != nil {
+			return err
 		}
+		cfg.Model = model
 	}
 
 	// Save configuration
@@ -417,22 +1815,32 @@ func editConfig() error {
 }
 
 func printCompletionScript() {
-	models := config.GetAvailableModels()
-	modelList := strings.Join(models, " ")
+	providerList := strings.Join(config.GetAvailableProviders(), " ")
 	fmt.Println(`# bash/zsh completion for ask
 _ask_completions() {
-    local cur prev opts models
+    local cur prev opts models providers
     COMPREPLY=()
     cur="${COMP_WORDS[COMP_CWORD]}"
     prev="${COMP_WORDS[COMP_CWORD-1]}"
-    opts="--setup --model --help --show-config --edit-config --clear --no-context --new-context --switch --list-contexts --delete-context completion"
-    models="` + modelList + `"
+    opts="--setup --model --provider --base-url --help --show-config --edit-config --clear --no-context --new-context --switch --list-contexts --delete-context --stream --no-stream --raw --output --code --code-lang --code-index --save-code --copy --tools --timeout --temperature --top-p --max-tokens --presence-penalty --frequency-penalty --seed --stop --reasoning-effort --file --interactive --compact --restore-history --system --persona --template --var chat cmd system sampling persona template mcp usage models completion"
+    models="$(ask models --all --offline --plain 2>/dev/null)"
+    providers="` + providerList + `"
 
     if [[ $prev == --model ]]; then
         COMPREPLY=( $(compgen -W "$models" -- $cur) )
         return 0
     fi
 
+    if [[ $prev == --provider ]]; then
+        COMPREPLY=( $(compgen -W "$providers" -- $cur) )
+        return 0
+    fi
+
+    if [[ $prev == --reasoning-effort ]]; then
+        COMPREPLY=( $(compgen -W "` + strings.Join(sampling.ReasoningEfforts, " ") + `" -- $cur) )
+        return 0
+    fi
+
     if [[ $cur == -* ]]; then
         COMPREPLY=( $(compgen -W "$opts" -- $cur) )
         return 0
@@ -497,6 +1905,9 @@ func listContexts(cfg *config.Config) {
 
 		fmt.Printf("%s %s (%s)\n", marker, contextName, context.ID)
 		fmt.Printf("    Messages: %d | Updated: %s\n", len(context.History), timeStr)
+		if context.Persona != "" {
+			fmt.Printf("    Persona: %s\n", context.Persona)
+		}
 
 		if i < len(contexts)-1 {
 			fmt.Println()
@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"ask/config"
+)
+
+const (
+	// ProtocolVersion is the MCP revision ask speaks
+	ProtocolVersion = "2024-11-05"
+	// initTimeout limits how long a server may take to start
+	initTimeout = 15 * time.Second
+	// maxStderr is how much of a server's stderr is kept for error messages
+	maxStderr = 4 << 10
+)
+
+// Client is a connection to an MCP server over stdio. Requests are JSON-RPC
+// 2.0 messages, one per line.
+type Client struct {
+	Name string // server name from the configuration
+	// Info is the server's name and version from the handshake
+	Info struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	Capabilities map[string]json.RawMessage
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tail
+
+	mu      sync.Mutex // guards writes and the fields below
+	nextID  int64
+	pending map[int64]chan *message
+	done    chan struct{} // closed when the server's stdout ends
+	err     error         // why the connection ended
+}
+
+// message is any JSON-RPC message: a request, notification or response
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is an error returned by the server
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Start runs the server and completes the MCP handshake
+func Start(ctx context.Context, name string, server config.MCPServer) (*Client, error) {
+	if server.Command == "" {
+		return nil, fmt.Errorf("MCP server %s has no command", name)
+	}
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range server.Env {
+		cmd.Env = append(cmd.Env, key+"="+os.ExpandEnv(value))
+	}
+	c := &Client{
+		Name:    name,
+		cmd:     cmd,
+		stderr:  &tail{},
+		pending: map[int64]chan *message{},
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = c.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %v", name, err)
+	}
+	c.stdin = stdin
+	go c.read(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      json.RawMessage            `json:"serverInfo"`
+	}
+	err = c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "ask", "version": "1.0"},
+	}, &result)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("MCP server %s failed to initialize: %v", name, err)
+	}
+	c.Capabilities = result.Capabilities
+	json.Unmarshal(result.ServerInfo, &c.Info)
+
+	if err := c.notify("notifications/initialized"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close ends the connection and stops the server
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+	}
+	c.cmd.Wait()
+	return nil
+}
+
+// has reports whether the server announced a capability, e.g. "tools"
+func (c *Client) has(capability string) bool {
+	_, ok := c.Capabilities[capability]
+	return ok
+}
+
+// read dispatches the server's messages until its stdout ends
+func (c *Client) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue // not a protocol message, e.g. stray logging
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.answer(&msg)
+		case msg.Method != "":
+			// Notifications such as progress or log messages are ignored
+		case msg.ID != nil:
+			c.mu.Lock()
+			ch := c.pending[*msg.ID]
+			delete(c.pending, *msg.ID)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.err = fmt.Errorf("MCP server %s exited", c.Name)
+	if err := scanner.Err(); err != nil {
+		c.err = fmt.Errorf("failed to read from MCP server %s: %v", c.Name, err)
+	} else if stderr := strings.TrimSpace(c.stderr.String()); stderr != "" {
+		c.err = fmt.Errorf("MCP server %s exited: %s", c.Name, stderr)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// answer replies to a request from the server. Only ping is supported.
+func (c *Client) answer(req *message) {
+	resp := &message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: -32601, Message: "method not supported by ask: " + req.Method}
+	}
+	c.send(resp)
+}
+
+// call sends a request and decodes its result into result
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.send(&message{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %v", method, err)
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		c.notifyParams("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string) error {
+	return c.notifyParams(method, nil)
+}
+
+func (c *Client) notifyParams(method string, params interface{}) error {
+	return c.send(&message{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (c *Client) send(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to MCP server %s: %v", c.Name, err)
+	}
+	return nil
+}
+
+// tail keeps the last maxStderr bytes written to it
+type tail struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf.Write(p)
+	if extra := t.buf.Len() - maxStderr; extra > 0 {
+		t.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"ask/config"
+	"ask/mcp/mcptest"
+)
+
+func TestMain(m *testing.M) {
+	mcptest.Main()
+	os.Exit(m.Run())
+}
+
+func start(t *testing.T) *Client {
+	t.Helper()
+	c, err := Start(context.Background(), "fixture", mcptest.Server())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestInitialize(t *testing.T) {
+	c := start(t)
+	if c.Info.Name != "mcptest" || c.Info.Version != "1.0" {
+		t.Errorf("Info = %+v, want mcptest 1.0", c.Info)
+	}
+	for _, capability := range []string{"tools", "resources", "prompts"} {
+		if !c.has(capability) {
+			t.Errorf("capability %s missing", capability)
+		}
+	}
+}
+
+func TestListTools(t *testing.T) {
+	c := start(t)
+	tools, err := c.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	// Both pages, with a ping from the server answered in between
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	want := "echo,fail," + mcptest.LongName + "_one," + mcptest.LongName + "_two,get.item,get_item"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("tools = %s, want %s", got, want)
+	}
+	if !strings.Contains(string(tools[0].InputSchema), `"text"`) {
+		t.Errorf("echo schema = %s", tools[0].InputSchema)
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	text, err := c.CallTool(ctx, "echo", []byte(`{"text":"hi there"}`))
+	if err != nil || text != "hi there" {
+		t.Errorf("CallTool(echo) = %q, %v; want \"hi there\"", text, err)
+	}
+	if _, err := c.CallTool(ctx, "fail", nil); err == nil || err.Error() != "it failed" {
+		t.Errorf("CallTool(fail) error = %v, want \"it failed\"", err)
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	resources, err := c.ListResources(ctx)
+	if err != nil || len(resources) != 1 || resources[0].URI != "test://greeting" {
+		t.Fatalf("ListResources = %+v, %v", resources, err)
+	}
+	text, err := c.ReadResource(ctx, "test://greeting")
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	if want := "hello\n[binary image/png content of test://logo omitted]"; text != want {
+		t.Errorf("ReadResource = %q, want %q", text, want)
+	}
+
+	_, err = c.ReadResource(ctx, "test://missing")
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32002 {
+		t.Errorf("ReadResource(missing) error = %v, want code -32002", err)
+	}
+}
+
+func TestListPrompts(t *testing.T) {
+	c := start(t)
+	prompts, err := c.ListPrompts(context.Background())
+	if err != nil {
+		t.Fatalf("ListPrompts: %v", err)
+	}
+	if len(prompts) != 1 || prompts[0].Name != "review" || len(prompts[0].Arguments) != 1 || !prompts[0].Arguments[0].Required {
+		t.Errorf("ListPrompts = %+v", prompts)
+	}
+}
+
+func TestStartAll(t *testing.T) {
+	disabled := mcptest.Server()
+	disabled.Disabled = true
+	clients, errs := StartAll(context.Background(), map[string]config.MCPServer{
+		"b":      mcptest.Server(),
+		"a":      mcptest.Server(),
+		"off":    disabled,
+		"broken": {Command: "/nonexistent/server"},
+	})
+	defer CloseAll(clients)
+
+	if len(clients) != 2 || clients[0].Name != "a" || clients[1].Name != "b" {
+		t.Errorf("started %d clients, want a and b", len(clients))
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken") {
+		t.Errorf("errors = %v, want one for broken", errs)
+	}
+}
+
+func TestServerExit(t *testing.T) {
+	c := start(t)
+	c.stdin.Close()
+	<-c.done
+	if _, err := c.ListTools(context.Background()); err == nil {
+		t.Error("ListTools after the server exited succeeded")
+	}
+}
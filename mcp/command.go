@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"ask/config"
+)
+
+// IsCommand reports whether arg is an action of the mcp subcommand
+func IsCommand(arg string) bool {
+	switch arg {
+	case "list", "tools", "resources", "prompts", "read":
+		return true
+	}
+	return false
+}
+
+// RunCommand runs ask mcp list|tools|resources|prompts [server] and
+// ask mcp read <server> <uri>
+func RunCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ask mcp list|tools|resources|prompts [server] or ask mcp read <server> <uri>")
+	}
+	if len(cfg.MCPServers) == 0 {
+		fmt.Println("🔌 No MCP servers configured.")
+		fmt.Printf("Add them under \"mcp_servers\" in %s\n", config.GetConfigPath())
+		return nil
+	}
+
+	action := args[0]
+	if action == "list" {
+		return listServers(cfg)
+	}
+
+	servers := cfg.MCPServers
+	if len(args) > 1 {
+		server, ok := cfg.MCPServers[args[1]]
+		if !ok {
+			return fmt.Errorf("unknown MCP server: %s", args[1])
+		}
+		server.Disabled = false
+		servers = map[string]config.MCPServer{args[1]: server}
+	}
+	if action == "read" && len(args) < 3 {
+		return fmt.Errorf("usage: ask mcp read <server> <uri>")
+	}
+
+	ctx := context.Background()
+	clients, errs := StartAll(ctx, servers)
+	defer CloseAll(clients)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+
+	for _, c := range clients {
+		var err error
+		switch action {
+		case "tools":
+			err = printTools(ctx, c)
+		case "resources":
+			err = printResources(ctx, c)
+		case "prompts":
+			err = printPrompts(ctx, c)
+		case "read":
+			var text string
+			text, err = c.ReadResource(ctx, args[2])
+			if err == nil {
+				fmt.Println(text)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.Name, err)
+		}
+	}
+	if len(errs) > 0 && len(clients) == 0 {
+		return fmt.Errorf("no MCP server could be started")
+	}
+	return nil
+}
+
+// listServers prints the configured servers without starting them
+func listServers(cfg *config.Config) error {
+	var names []string
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("🔌 MCP servers:")
+	for _, name := range names {
+		server := cfg.MCPServers[name]
+		line := strings.TrimSpace(server.Command + " " + strings.Join(server.Args, " "))
+		if server.Policy != "" {
+			line += fmt.Sprintf(" [policy: %s]", server.Policy)
+		}
+		if server.Disabled {
+			line += " (disabled)"
+		}
+		fmt.Printf("  %s: %s\n", name, line)
+	}
+	return nil
+}
+
+func printTools(ctx context.Context, c *Client) error {
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔧 %s: %d tools\n", c.Name, len(tools))
+	for _, tool := range tools {
+		fmt.Printf("  %s%s\n", tool.Name, summary(tool.Description))
+	}
+	return nil
+}
+
+func printResources(ctx context.Context, c *Client) error {
+	resources, err := c.ListResources(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📄 %s: %d resources\n", c.Name, len(resources))
+	for _, resource := range resources {
+		name := resource.URI
+		if resource.Name != "" && resource.Name != resource.URI {
+			name += " (" + resource.Name + ")"
+		}
+		fmt.Printf("  %s%s\n", name, summary(resource.Description))
+	}
+	return nil
+}
+
+func printPrompts(ctx context.Context, c *Client) error {
+	prompts, err := c.ListPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📝 %s: %d prompts\n", c.Name, len(prompts))
+	for _, prompt := range prompts {
+		var args []string
+		for _, arg := range prompt.Arguments {
+			if arg.Required {
+				args = append(args, arg.Name)
+			} else {
+				args = append(args, arg.Name+"?")
+			}
+		}
+		name := prompt.Name
+		if len(args) > 0 {
+			name += " (" + strings.Join(args, ", ") + ")"
+		}
+		fmt.Printf("  %s%s\n", name, summary(prompt.Description))
+	}
+	return nil
+}
+
+// summary returns the first line of a description, shortened for listings
+func summary(description string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(description), "\n")
+	if line == "" {
+		return ""
+	}
+	if len(line) > 80 {
+		line = line[:77] + "..."
+	}
+	return " - " + line
+}
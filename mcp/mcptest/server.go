@@ -0,0 +1,139 @@
+// Package mcptest provides an MCP server over stdio for tests. The server
+// runs in the test binary itself: TestMain calls Main, which serves MCP
+// instead of running the tests when the binary was started by Server.
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"ask/config"
+)
+
+// envVar is set in the environment of the test binary when it runs as the
+// server
+const envVar = "ASK_MCPTEST_SERVER"
+
+// Main serves MCP on stdin and stdout and exits if the test binary was
+// started as the server. Call it first in TestMain.
+func Main() {
+	if os.Getenv(envVar) == "" {
+		return
+	}
+	Serve(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+// Server returns the configuration that starts the test binary as the
+// server
+func Server() config.MCPServer {
+	return config.MCPServer{Command: os.Args[0], Env: map[string]string{envVar: "1"}}
+}
+
+// LongName is the start of two tool names too long to use whole
+var LongName = "lookup_" + strings.Repeat("x", 60)
+
+// Tools are the tools the server offers. tools/list returns them in two
+// pages. echo returns its text argument and fail always fails; the others,
+// whose names clash once shortened or sanitized, say they were called.
+var Tools = []map[string]interface{}{
+	{"name": "echo", "description": "Return the text", "inputSchema": map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"text": map[string]string{"type": "string"}},
+	}},
+	{"name": "fail", "description": "Always fails"},
+	{"name": LongName + "_one"},
+	{"name": LongName + "_two"},
+	{"name": "get.item"},
+	{"name": "get_item"},
+}
+
+type request struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+		Cursor    string          `json:"cursor"`
+		URI       string          `json:"uri"`
+	} `json:"params"`
+}
+
+// Serve answers MCP requests read from in until it ends
+func Serve(in io.Reader, out io.Writer) {
+	encoder := json.NewEncoder(out)
+	reply := func(id *json.RawMessage, result interface{}, code int, message string) {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+		if code != 0 {
+			msg["error"] = map[string]interface{}{"code": code, "message": message}
+		} else {
+			msg["result"] = result
+		}
+		encoder.Encode(msg)
+	}
+	text := func(s string) []map[string]string {
+		return []map[string]string{{"type": "text", "text": s}}
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil || req.Method == "" {
+			continue // notifications and answers to our ping
+		}
+
+		switch req.Method {
+		case "initialize":
+			reply(req.ID, map[string]interface{}{
+				"protocolVersion": "2024-11-05",
+				"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{}, "prompts": map[string]interface{}{}},
+				"serverInfo":      map[string]string{"name": "mcptest", "version": "1.0"},
+			}, 0, "")
+		case "tools/list":
+			// The client has to answer requests from the server meanwhile
+			encoder.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 9000, "method": "ping"})
+			if req.Params.Cursor == "" {
+				reply(req.ID, map[string]interface{}{"tools": Tools[:1], "nextCursor": "page2"}, 0, "")
+			} else {
+				reply(req.ID, map[string]interface{}{"tools": Tools[1:]}, 0, "")
+			}
+		case "tools/call":
+			var args struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(req.Params.Arguments, &args)
+			switch req.Params.Name {
+			case "fail":
+				reply(req.ID, map[string]interface{}{"content": text("it failed"), "isError": true}, 0, "")
+			case "echo":
+				reply(req.ID, map[string]interface{}{"content": text(args.Text)}, 0, "")
+			default:
+				reply(req.ID, map[string]interface{}{"content": text(req.Params.Name + " called")}, 0, "")
+			}
+		case "resources/list":
+			reply(req.ID, map[string]interface{}{"resources": []map[string]string{
+				{"uri": "test://greeting", "name": "greeting", "mimeType": "text/plain"},
+			}}, 0, "")
+		case "resources/read":
+			if req.Params.URI != "test://greeting" {
+				reply(req.ID, nil, -32002, "resource not found: "+req.Params.URI)
+				continue
+			}
+			reply(req.ID, map[string]interface{}{"contents": []map[string]string{
+				{"uri": "test://greeting", "text": "hello"},
+				{"uri": "test://logo", "blob": "AAAA", "mimeType": "image/png"},
+			}}, 0, "")
+		case "prompts/list":
+			reply(req.ID, map[string]interface{}{"prompts": []map[string]interface{}{
+				{"name": "review", "description": "Review code", "arguments": []map[string]interface{}{
+					{"name": "file", "required": true},
+				}},
+			}}, 0, "")
+		default:
+			reply(req.ID, nil, -32601, "method not found: "+req.Method)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tool is a tool offered by a server
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// Resource is a piece of data a server can return by URI
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+// Prompt is a prompt template offered by a server
+type Prompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Arguments   []struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Required    bool   `json:"required"`
+	} `json:"arguments"`
+}
+
+// content is an item of a tool result or resource
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		Text     string `json:"text"`
+		MimeType string `json:"mimeType"`
+	} `json:"resource"`
+}
+
+// ListTools returns the server's tools
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	if !c.has("tools") {
+		return nil, nil
+	}
+	err := c.list(ctx, "tools/list", func(raw json.RawMessage) error {
+		var page struct {
+			Tools []Tool `json:"tools"`
+		}
+		err := json.Unmarshal(raw, &page)
+		tools = append(tools, page.Tools...)
+		return err
+	})
+	return tools, err
+}
+
+// ListResources returns the server's resources
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	if !c.has("resources") {
+		return nil, nil
+	}
+	err := c.list(ctx, "resources/list", func(raw json.RawMessage) error {
+		var page struct {
+			Resources []Resource `json:"resources"`
+		}
+		err := json.Unmarshal(raw, &page)
+		resources = append(resources, page.Resources...)
+		return err
+	})
+	return resources, err
+}
+
+// ListPrompts returns the server's prompts
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	if !c.has("prompts") {
+		return nil, nil
+	}
+	err := c.list(ctx, "prompts/list", func(raw json.RawMessage) error {
+		var page struct {
+			Prompts []Prompt `json:"prompts"`
+		}
+		err := json.Unmarshal(raw, &page)
+		prompts = append(prompts, page.Prompts...)
+		return err
+	})
+	return prompts, err
+}
+
+// list calls a paginated list method, passing each page to add
+func (c *Client) list(ctx context.Context, method string, add func(json.RawMessage) error) error {
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page json.RawMessage
+		if err := c.call(ctx, method, params, &page); err != nil {
+			return fmt.Errorf("%s failed: %v", method, err)
+		}
+		if err := add(page); err != nil {
+			return fmt.Errorf("invalid %s result: %v", method, err)
+		}
+		var next struct {
+			NextCursor string `json:"nextCursor"`
+		}
+		json.Unmarshal(page, &next)
+		if next.NextCursor == "" || next.NextCursor == cursor {
+			return nil
+		}
+		cursor = next.NextCursor
+	}
+}
+
+// CallTool runs a tool with JSON arguments and returns its text output. A
+// result the server flags as an error is returned as an error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (string, error) {
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return "", err
+	}
+	text := joinContent(result.Content)
+	if result.IsError {
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+// ReadResource returns the text of a resource
+func (c *Client) ReadResource(ctx context.Context, uri string) (string, error) {
+	var result struct {
+		Contents []struct {
+			URI      string `json:"uri"`
+			Text     string `json:"text"`
+			Blob     string `json:"blob"`
+			MimeType string `json:"mimeType"`
+		} `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, item := range result.Contents {
+		if item.Blob != "" {
+			parts = append(parts, fmt.Sprintf("[binary %s content of %s omitted]", item.MimeType, item.URI))
+			continue
+		}
+		parts = append(parts, item.Text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// joinContent returns the text of a tool result, noting any content that
+// cannot be shown as text
+func joinContent(items []content) string {
+	var parts []string
+	for _, item := range items {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil && item.Resource.Text != "":
+			parts = append(parts, item.Resource.Text)
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", item.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
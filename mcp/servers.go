@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ask/config"
+)
+
+// StartAll starts the enabled servers in servers, sorted by name. Servers
+// that fail to start are left out and their errors returned alongside.
+func StartAll(ctx context.Context, servers map[string]config.MCPServer) ([]*Client, []error) {
+	var names []string
+	for name, server := range servers {
+		if !server.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	clients := make([]*Client, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			clients[i], errs[i] = Start(ctx, name, servers[name])
+		}(i, name)
+	}
+	wg.Wait()
+
+	var started []*Client
+	var failed []error
+	for i := range names {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+		} else {
+			started = append(started, clients[i])
+		}
+	}
+	return started, failed
+}
+
+// CloseAll closes every client
+func CloseAll(clients []*Client) {
+	for _, c := range clients {
+		c.Close()
+	}
+}
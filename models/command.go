@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"ask/config"
+)
+
+// RunCommand runs ask models [--provider name] [--refresh] [--offline]
+// [--all] [--plain]. providerName is the provider to list by default and
+// baseURL returns the base URL to use for a provider.
+func RunCommand(cfg *config.Config, args []string, providerName string, baseURL func(provider string) string) error {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	providerFlag := fs.String("provider", providerName, "List the models of this provider")
+	refresh := fs.Bool("refresh", false, "Fetch the list even if the cached one is fresh")
+	offline := fs.Bool("offline", false, "Only use the cached or built-in list")
+	all := fs.Bool("all", false, "List the models of every provider")
+	plain := fs.Bool("plain", false, "Print only the model names, one per line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !config.IsValidProvider(*providerFlag) {
+		return fmt.Errorf("unknown provider: %s (available: %v)", *providerFlag, config.GetAvailableProviders())
+	}
+
+	providers := []string{*providerFlag}
+	if *all {
+		providers = config.GetAvailableProviders()
+	}
+	opts := Options{Refresh: *refresh, Offline: *offline}
+
+	for i, name := range providers {
+		list := Load(context.Background(), cfg, name, baseURL(name), opts)
+		if *plain {
+			for _, model := range list.Models {
+				fmt.Println(model)
+			}
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("🤖 %s models (%s):\n", name, list.Describe())
+		width := 0
+		for _, model := range list.Models {
+			if len(model) > width {
+				width = len(model)
+			}
+		}
+		for _, model := range list.Models {
+			marker := " "
+			if name == cfg.GetProvider() && model == cfg.Model {
+				marker = "*"
+			}
+			line := fmt.Sprintf("  %s %-*s  %s", marker, width, model, capabilities(model))
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+		if list.Err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not fetch the %s models: %v\n", name, list.Err)
+		}
+	}
+	return nil
+}
+
+// capabilities describes a model in the list, or nothing for unknown models
+func capabilities(model string) string {
+	info := config.LookupModel(model)
+	if !info.Known {
+		return ""
+	}
+	return info.Capabilities()
+}
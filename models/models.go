@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ask/config"
+	"ask/provider"
+)
+
+// CacheTTL is how long a fetched model list is used before fetching it again
+const CacheTTL = 24 * time.Hour
+
+// fetchTimeout limits how long fetching a model list may take
+const fetchTimeout = 15 * time.Second
+
+// Where a model list came from
+const (
+	SourceLive    = "live"     // fetched from the provider just now
+	SourceCache   = "cache"    // fetched earlier
+	SourceBuiltIn = "built-in" // the list shipped with ask
+)
+
+// List is the models offered by a provider
+type List struct {
+	Provider string
+	Models   []string
+	Source   string
+	Fetched  time.Time // when the list was fetched, zero for the built-in list
+	Err      error     // why the list could not be fetched, if it was not
+}
+
+// Options control where a list may come from
+type Options struct {
+	Refresh bool // fetch the list even if the cached one is fresh
+	Offline bool // never fetch, only use the cached or built-in list
+}
+
+// cacheEntry is a model list saved in the cache file
+type cacheEntry struct {
+	Fetched time.Time `json:"fetched"`
+	Models  []string  `json:"models"`
+}
+
+// CachePath returns the path of the model list cache
+func CachePath() string {
+	return filepath.Join(config.GetConfigDir(), "models.json")
+}
+
+// Load returns the models of a provider at baseURL: the cached list while
+// it is fresh, else a newly fetched one. When fetching fails it falls back
+// to a stale cached list, then to the built-in list.
+func Load(ctx context.Context, cfg *config.Config, providerName, baseURL string, opts Options) *List {
+	key := providerName + " " + baseURL
+	cache := readCache()
+	cached, ok := cache[key]
+
+	if ok && (opts.Offline || (!opts.Refresh && time.Since(cached.Fetched) < CacheTTL)) {
+		return &List{Provider: providerName, Models: cached.Models, Source: SourceCache, Fetched: cached.Fetched}
+	}
+	if opts.Offline {
+		return builtIn(providerName, nil)
+	}
+
+	names, err := fetch(ctx, cfg, providerName, baseURL)
+	if err != nil {
+		if ok {
+			return &List{Provider: providerName, Models: cached.Models, Source: SourceCache, Fetched: cached.Fetched, Err: err}
+		}
+		return builtIn(providerName, err)
+	}
+
+	now := time.Now()
+	if err := saveCacheEntry(key, cacheEntry{Fetched: now, Models: names}); err != nil {
+		return &List{Provider: providerName, Models: names, Source: SourceLive, Fetched: now, Err: err}
+	}
+	return &List{Provider: providerName, Models: names, Source: SourceLive, Fetched: now}
+}
+
+// Has reports whether model is in the list
+func (l *List) Has(model string) bool {
+	for _, name := range l.Models {
+		if name == model || name == strings.TrimSuffix(model, ":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe says where the list came from, e.g. "cached 2h ago"
+func (l *List) Describe() string {
+	switch l.Source {
+	case SourceLive:
+		return "fetched just now"
+	case SourceCache:
+		if time.Since(l.Fetched) < time.Minute {
+			return "cached just now"
+		}
+		return fmt.Sprintf("cached %s ago", age(time.Since(l.Fetched)))
+	}
+	return "built-in list"
+}
+
+func builtIn(providerName string, err error) *List {
+	return &List{Provider: providerName, Models: config.GetAvailableModels(providerName), Source: SourceBuiltIn, Err: err}
+}
+
+// fetch asks the provider for its models
+func fetch(ctx context.Context, cfg *config.Config, providerName, baseURL string) ([]string, error) {
+	if config.RequiresAPIKey(providerName, baseURL) && cfg.GetAPIKey(providerName) == "" {
+		return nil, fmt.Errorf("no API key configured for %s", providerName)
+	}
+	p, err := provider.New(providerName, provider.Settings{
+		APIKey:   cfg.GetAPIKey(providerName),
+		BaseURL:  baseURL,
+		Timeouts: provider.Timeouts{Connect: 5 * time.Second, FirstByte: 10 * time.Second},
+	})
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := p.(provider.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot list its models", providerName)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	names, err := lister.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s returned no models", providerName)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readCache() map[string]cacheEntry {
+	cache := map[string]cacheEntry{}
+	data, err := ioutil.ReadFile(CachePath())
+	if err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// saveCacheEntry stores the list fetched for key. The cache is read again
+// under the configuration lock so that lists other ask processes saved
+// meanwhile are kept, and replaced at once so that it is never truncated.
+func saveCacheEntry(key string, entry cacheEntry) error {
+	release, err := config.Lock()
+	if err != nil {
+		return fmt.Errorf("failed to save model list: %v", err)
+	}
+	defer release()
+
+	cache := readCache()
+	cache[key] = entry
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := config.WriteFileAtomic(CachePath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to save model list: %v", err)
+	}
+	return nil
+}
+
+// age formats a duration roughly, e.g. "5m" or "3h"
+func age(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// Choose returns the model picked by input, either its number in the list
+// counting from 1 or its name. Names missing from a fetched list are
+// rejected, but any name is accepted when only the built-in list is known.
+func (l *List) Choose(input string) (string, error) {
+	var choice int
+	if _, err := fmt.Sscanf(input, "%d", &choice); err == nil && fmt.Sprint(choice) == input {
+		if choice < 1 || choice > len(l.Models) {
+			return "", fmt.Errorf("invalid choice. Please select a number between 1 and %d", len(l.Models))
+		}
+		return l.Models[choice-1], nil
+	}
+	if l.Has(input) || l.Source == SourceBuiltIn {
+		return input, nil
+	}
+	return "", fmt.Errorf("unknown model: %s (see 'ask models --provider %s')", input, l.Provider)
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"ask/provider"
+)
+
+// Output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"  // one JSON object once the answer is complete
+	FormatJSONL = "jsonl" // a JSON line per streamed piece, then the result
+)
+
+// Exit codes. They are part of the command line interface and must not
+// change, so that scripts can rely on them.
+const (
+	ExitOK        = 0
+	ExitError     = 1 // any failure not listed below
+	ExitBadInput  = 2 // invalid flags, arguments, templates or input files
+	ExitAuth      = 3 // missing or rejected API key
+	ExitRateLimit = 4 // the provider's rate limit was hit
+	ExitNetwork   = 5 // the provider could not be reached
+	ExitAPI       = 6 // the provider returned any other error
+	ExitTimeout   = 7 // the request took longer than its timeout
+	// ExitInterrupted follows the shell convention for SIGINT
+	ExitInterrupted = 130
+)
+
+// errorTypes names the exit codes in JSON results
+var errorTypes = map[int]string{
+	ExitError:       "error",
+	ExitBadInput:    "bad_input",
+	ExitAuth:        "auth",
+	ExitRateLimit:   "rate_limit",
+	ExitNetwork:     "network",
+	ExitAPI:         "api",
+	ExitTimeout:     "timeout",
+	ExitInterrupted: "interrupted",
+}
+
+// IsValidFormat reports whether format is a known output format
+func IsValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON || format == FormatJSONL
+}
+
+// Result is the machine-readable outcome of a request
+type Result struct {
+	Answer       string          `json:"answer"`
+	Model        string          `json:"model,omitempty"`
+	Provider     string          `json:"provider,omitempty"`
+	ContextID    string          `json:"context_id,omitempty"`
+	Usage        *provider.Usage `json:"usage,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	LatencyMS    int64           `json:"latency_ms"`
+	Error        *Error          `json:"error,omitempty"`
+	Truncated    bool            `json:"truncated,omitempty"` // the answer was interrupted
+}
+
+// Error describes a failed request
+type Error struct {
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+	Status   int    `json:"status,omitempty"` // HTTP status of an API error
+	Code     string `json:"code,omitempty"`   // error code of an API error, e.g. "rate_limit_exceeded"
+	ExitCode int    `json:"exit_code"`
+}
+
+// NewError describes err for a Result
+func NewError(err error) *Error {
+	code := ExitCode(err)
+	e := &Error{Type: errorTypes[code], Message: err.Error(), ExitCode: code}
+	var apiErr *provider.APIError
+	if errors.As(err, &apiErr) {
+		e.Status = apiErr.StatusCode
+		e.Code = apiErr.Code
+	}
+	return e
+}
+
+// codedError is an error with a known exit code
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// WithCode marks err to exit with code
+func WithCode(code int, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// BadInput marks err as caused by invalid input
+func BadInput(err error) error {
+	return WithCode(ExitBadInput, err)
+}
+
+// ExitCode returns the exit code for err
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	var apiErr *provider.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.IsAuth():
+			return ExitAuth
+		case apiErr.IsRateLimit():
+			return ExitRateLimit
+		}
+		return ExitAPI
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+	if provider.IsTimeout(err) {
+		return ExitTimeout
+	}
+	if provider.IsNetworkError(err) {
+		return ExitNetwork
+	}
+	return ExitError
+}
+
+// WriteDelta writes a piece of a streamed answer as a JSON line
+func WriteDelta(w io.Writer, text string) error {
+	return writeJSON(w, struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{"delta", text})
+}
+
+// WriteResult writes r in the given format. In JSON lines it is marked as
+// the final line.
+func WriteResult(w io.Writer, format string, r *Result) error {
+	if format == FormatJSONL {
+		return writeJSON(w, struct {
+			Type string `json:"type"`
+			*Result
+		}{"result", r})
+	}
+	return writeJSON(w, r)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %v", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
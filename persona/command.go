@@ -0,0 +1,221 @@
+package persona
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"ask/config"
+)
+
+// IsCommand reports whether arg is an action of the persona subcommand
+func IsCommand(arg string) bool {
+	switch arg {
+	case "list", "add", "edit", "remove", "show":
+		return true
+	}
+	return false
+}
+
+// RunCommand runs ask persona list|show|add|edit|remove
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ask persona list|show|add|edit|remove [name]")
+	}
+
+	action := args[0]
+	if action == "list" {
+		return listPersonas()
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: ask persona %s <name>", action)
+	}
+	name := args[1]
+
+	switch action {
+	case "show":
+		p, err := Load(name)
+		if err != nil {
+			return err
+		}
+		printPersona(p)
+		return nil
+	case "remove":
+		if err := Remove(name); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Deleted persona: %s\n", name)
+		return detach(name)
+	case "add":
+		if Exists(name) {
+			return fmt.Errorf("persona '%s' already exists (use 'ask persona edit %s')", name, name)
+		}
+		return editPersona(&Persona{Name: name}, args[2:], true)
+	case "edit":
+		p, err := Load(name)
+		if err != nil {
+			return err
+		}
+		return editPersona(p, args[2:], false)
+	}
+	return fmt.Errorf("unknown persona command: %s", action)
+}
+
+// detach detaches a deleted persona from the contexts that use it, so that
+// asking in them does not fail
+func detach(name string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	contexts := cfg.DetachPersona(name)
+	if len(contexts) == 0 {
+		return nil
+	}
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to detach persona %s: %v", name, err)
+	}
+	fmt.Printf("Detached it from: %s\n", strings.Join(contexts, ", "))
+	return nil
+}
+
+// editPersona updates p from flags, or interactively when no flags are
+// given, and saves it
+func editPersona(p *Persona, args []string, isNew bool) error {
+	fs := flag.NewFlagSet("persona", flag.ContinueOnError)
+	system := fs.String("system", "", "System prompt")
+	model := fs.String("model", "", "Default model")
+	temperature := fs.String("temperature", "", "Sampling temperature (\"default\" to unset)")
+	format := fs.String("format", "", "Output format, e.g. \"a conventional commit message\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NFlag() == 0 {
+		if err := promptPersona(p); err != nil {
+			return err
+		}
+	} else {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "system":
+				p.SystemPrompt = *system
+			case "model":
+				p.Model = *model
+			case "format":
+				p.OutputFormat = *format
+			}
+		})
+		if *temperature != "" {
+			if err := setTemperature(p, *temperature); err != nil {
+				return err
+			}
+		}
+	}
+
+	if strings.TrimSpace(p.SystemPrompt) == "" && p.OutputFormat == "" {
+		return fmt.Errorf("a persona needs a system prompt or an output format")
+	}
+	if err := Save(p); err != nil {
+		return err
+	}
+
+	if isNew {
+		fmt.Printf("✅ Created persona: %s\n", p.Name)
+	} else {
+		fmt.Printf("✅ Updated persona: %s\n", p.Name)
+	}
+	return nil
+}
+
+// promptPersona asks for each field, keeping the current value on Enter
+func promptPersona(p *Persona) error {
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(label, current string) string {
+		if current != "" {
+			fmt.Printf("%s [%s]: ", label, current)
+		} else {
+			fmt.Printf("%s: ", label)
+		}
+		value, _ := reader.ReadString('\n')
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return current
+		}
+		return value
+	}
+
+	fmt.Printf("🎭 Persona: %s\n", p.Name)
+	fmt.Println("Leave blank to keep the current value.")
+	p.SystemPrompt = ask("System prompt", p.SystemPrompt)
+	p.Model = ask("Default model (blank for the configured model)", p.Model)
+
+	current := ""
+	if p.Temperature != nil {
+		current = strconv.FormatFloat(*p.Temperature, 'g', -1, 64)
+	}
+	if value := ask("Temperature (\"default\" to unset)", current); value != current {
+		if err := setTemperature(p, value); err != nil {
+			return err
+		}
+	}
+
+	p.OutputFormat = ask("Output format", p.OutputFormat)
+	return nil
+}
+
+func setTemperature(p *Persona, value string) error {
+	if value == "default" {
+		p.Temperature = nil
+		return nil
+	}
+	t, err := strconv.ParseFloat(value, 64)
+	if err != nil || t < 0 || t > 2 {
+		return fmt.Errorf("invalid temperature %q: must be between 0 and 2", value)
+	}
+	p.Temperature = &t
+	return nil
+}
+
+func listPersonas() error {
+	personas, err := List()
+	if err != nil {
+		return err
+	}
+	if len(personas) == 0 {
+		fmt.Println("🎭 No personas found.")
+		fmt.Println("Create one with: ask persona add reviewer --system \"You are a strict code reviewer\"")
+		return nil
+	}
+
+	fmt.Println("🎭 Available personas:")
+	fmt.Println()
+	for i, p := range personas {
+		printPersona(&p)
+		if i < len(personas)-1 {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+func printPersona(p *Persona) {
+	fmt.Printf("%s\n", p.Name)
+	prompt := p.SystemPrompt
+	if len(prompt) > 70 {
+		prompt = prompt[:67] + "..."
+	}
+	fmt.Printf("    System prompt: %s\n", prompt)
+	if p.Model != "" {
+		fmt.Printf("    Model: %s\n", p.Model)
+	}
+	if p.Temperature != nil {
+		fmt.Printf("    Temperature: %g\n", *p.Temperature)
+	}
+	if p.OutputFormat != "" {
+		fmt.Printf("    Output format: %s\n", p.OutputFormat)
+	}
+}
@@ -0,0 +1,143 @@
+package persona
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ask/config"
+)
+
+// Persona is a named preset of instructions and settings for a role such as
+// a code reviewer or a commit-message writer
+type Persona struct {
+	Name         string   `json:"name"`
+	SystemPrompt string   `json:"system_prompt"`
+	Model        string   `json:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	OutputFormat string   `json:"output_format,omitempty"`
+}
+
+// Instructions returns the system prompt for the persona, including its
+// output format
+func (p *Persona) Instructions() string {
+	if p.OutputFormat == "" {
+		return p.SystemPrompt
+	}
+	format := "Format your answer as: " + p.OutputFormat
+	if p.SystemPrompt == "" {
+		return format
+	}
+	return p.SystemPrompt + "\n\n" + format
+}
+
+// Dir returns the directory holding the persona files
+func Dir() string {
+	return filepath.Join(config.GetConfigDir(), "personas")
+}
+
+// path returns the file for a persona name
+func path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid persona name: %q", name)
+	}
+	return filepath.Join(Dir(), name+".json"), nil
+}
+
+// Load reads a persona by name
+func Load(name string) (*Persona, error) {
+	file, err := path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("persona not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persona %s: %v", name, err)
+	}
+
+	var p Persona
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse persona %s: %v", name, err)
+	}
+	p.Name = name
+	return &p, nil
+}
+
+// Exists reports whether a persona with the given name is stored
+func Exists(name string) bool {
+	file, err := path(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(file)
+	return err == nil
+}
+
+// Save writes a persona, replacing any persona with the same name
+func Save(p *Persona) error {
+	file, err := path(p.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return fmt.Errorf("failed to create personas directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal persona: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return fmt.Errorf("failed to write persona %s: %v", p.Name, err)
+	}
+	return nil
+}
+
+// Remove deletes a persona
+func Remove(name string) error {
+	file, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); os.IsNotExist(err) {
+		return fmt.Errorf("persona not found: %s", name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete persona %s: %v", name, err)
+	}
+	return nil
+}
+
+// List returns all stored personas sorted by name
+func List() ([]Persona, error) {
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personas directory: %v", err)
+	}
+
+	var personas []Persona
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		p, err := Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		personas = append(personas, *p)
+	}
+
+	sort.Slice(personas, func(i, j int) bool {
+		return personas[i].Name < personas[j].Name
+	})
+	return personas, nil
+}
@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ask/config"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+
+	// anthropicMaxTokens is sent with every request since the Messages API
+	// requires an explicit output limit
+	anthropicMaxTokens = 4096
+)
+
+type anthropicProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicStreamEvent is a single server-sent event of a streamed message.
+// Only the fields needed to assemble the text and usage are decoded.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newAnthropic(settings Settings) Provider {
+	return &anthropicProvider{apiKey: settings.APIKey, baseURL: settings.BaseURL, client: newHTTPClient(settings.Timeouts)}
+}
+
+func (p *anthropicProvider) Name() string {
+	return config.ProviderAnthropic
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error) {
+	stream := onDelta != nil
+
+	// The Messages API takes the system prompt as a top-level field rather
+	// than as a message
+	anthReq := anthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     anthropicMaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+	}
+	if req.MaxTokens > 0 {
+		anthReq.MaxTokens = req.MaxTokens
+	}
+	var system []string
+	for _, msg := range withoutTools(req.Messages) {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		anthReq.Messages = append(anthReq.Messages, anthropicMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+	anthReq.System = strings.Join(system, "\n\n")
+
+	body, err := json.Marshal(anthReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint(p.baseURL, anthropicBaseURL, "/v1/messages"), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("Anthropic", resp)
+	}
+
+	if stream {
+		var full strings.Builder
+		var usage anthropicUsage
+		result := &Response{}
+		err := readSSE(resp.Body, func(data string) (bool, error) {
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return false, fmt.Errorf("failed to decode stream event: %v", err)
+			}
+			switch event.Type {
+			case "message_start":
+				usage.InputTokens = event.Message.Usage.InputTokens
+			case "message_delta":
+				if event.Delta.StopReason != "" {
+					result.FinishReason = event.Delta.StopReason
+				}
+				usage.OutputTokens = event.Usage.OutputTokens
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					full.WriteString(event.Delta.Text)
+					onDelta(event.Delta.Text)
+				}
+			case "message_stop":
+				return true, nil
+			case "error":
+				return false, fmt.Errorf("Anthropic API error: %s", event.Error.Message)
+			}
+			return false, nil
+		})
+		result.Content = full.String()
+		if usage.InputTokens > 0 || usage.OutputTokens > 0 {
+			result.Usage = usage.toUsage()
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read streamed response: %w", err)
+		}
+		return result, nil
+	}
+
+	var msgResp anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	var text strings.Builder
+	for _, block := range msgResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return &Response{
+		Content:      text.String(),
+		FinishReason: msgResp.StopReason,
+		Usage:        msgResp.Usage.toUsage(),
+	}, nil
+}
+
+func (u anthropicUsage) toUsage() *Usage {
+	return &Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is an error response from a provider's API
+type APIError struct {
+	Provider   string // provider display name, e.g. "OpenAI"
+	StatusCode int
+	Body       string
+	Message    string        // human-readable message from the error JSON
+	Type       string        // error type, e.g. "invalid_request_error"
+	Code       string        // error code, e.g. "rate_limit_exceeded"
+	RetryAfter time.Duration // when the API asked to be retried, zero if it did not
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Body)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("%s API error: %s (%s)", e.Provider, e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s API error: %s", e.Provider, e.Message)
+}
+
+// IsAuth reports whether the request was rejected for its credentials
+func (e *APIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsRateLimit reports whether the request was rejected by a rate limit
+func (e *APIError) IsRateLimit() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsTemporary reports whether the request may succeed when retried: rate
+// limits, timeouts and server errors. An exhausted quota is not temporary.
+func (e *APIError) IsTemporary() bool {
+	if e.Code == "insufficient_quota" || e.Type == "insufficient_quota" {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout || e.StatusCode >= 500
+}
+
+// newAPIError reads the error response resp
+func newAPIError(provider string, resp *http.Response) *APIError {
+	b, _ := ioutil.ReadAll(resp.Body)
+	body := strings.TrimSpace(string(b))
+	if body == "" {
+		body = resp.Status
+	}
+	e := &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: body}
+	e.parseBody(b)
+	e.RetryAfter = retryAfter(resp.Header, time.Now())
+	return e
+}
+
+// parseBody fills in the message, type and code from an error body. OpenAI
+// and Anthropic send {"error": {"message": ..., "type": ..., "code": ...}},
+// Ollama sends {"error": "message"}.
+func (e *APIError) parseBody(body []byte) {
+	var wrapper struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &wrapper); err != nil || len(wrapper.Error) == 0 {
+		return
+	}
+	var message string
+	if err := json.Unmarshal(wrapper.Error, &message); err == nil {
+		e.Message = message
+		return
+	}
+	var detail struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"` // a string, a number or null
+	}
+	if err := json.Unmarshal(wrapper.Error, &detail); err != nil {
+		return
+	}
+	e.Message = strings.TrimSpace(detail.Message)
+	e.Type = detail.Type
+	var code string
+	if err := json.Unmarshal(detail.Code, &code); err == nil {
+		e.Code = code
+	} else if len(detail.Code) > 0 && string(detail.Code) != "null" {
+		e.Code = string(detail.Code)
+	}
+}
+
+// rateLimits pairs the headers giving what is left of each rate limit with
+// those giving when it resets
+var rateLimits = []struct{ remaining, reset string }{
+	{"X-Ratelimit-Remaining", "X-Ratelimit-Reset"},
+	{"X-Ratelimit-Remaining-Requests", "X-Ratelimit-Reset-Requests"},
+	{"X-Ratelimit-Remaining-Tokens", "X-Ratelimit-Reset-Tokens"},
+	{"Anthropic-Ratelimit-Requests-Remaining", "Anthropic-Ratelimit-Requests-Reset"},
+	{"Anthropic-Ratelimit-Tokens-Remaining", "Anthropic-Ratelimit-Tokens-Reset"},
+	{"Anthropic-Ratelimit-Input-Tokens-Remaining", "Anthropic-Ratelimit-Input-Tokens-Reset"},
+	{"Anthropic-Ratelimit-Output-Tokens-Remaining", "Anthropic-Ratelimit-Output-Tokens-Reset"},
+}
+
+// retryAfter returns how long the response headers ask to wait before
+// retrying: Retry-After (seconds or a date), else when the exhausted rate
+// limits reset. Limits with requests or tokens left don't matter, however
+// long until they reset. It returns zero when there is no such header.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+
+	var longest time.Duration
+	for _, limit := range rateLimits {
+		if remaining, err := strconv.Atoi(header.Get(limit.remaining)); err != nil || remaining > 0 {
+			continue
+		}
+		if wait := parseReset(header.Get(limit.reset), now); wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+// parseReset parses a rate limit reset header, which is a duration such as
+// "6m0s" or "20ms", a number of seconds, a Unix time or an RFC 3339 time
+func parseReset(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		// Large numbers are points in time rather than delays
+		if n > 1e9 {
+			return time.Unix(int64(n), 0).Sub(now)
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// IsTimeout reports whether err was caused by a timeout, while connecting,
+// waiting for the response or of the whole request
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsNetworkError reports whether err was caused by a failed connection
+// rather than by a response from the API
+func IsNetworkError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Timeouts limit how long a request may take. Zero means no limit.
+type Timeouts struct {
+	Connect   time.Duration // to establish the connection
+	FirstByte time.Duration // from sending the request to the first byte of the response
+	Total     time.Duration // for the whole request, applied by the caller's context
+}
+
+// DefaultTimeouts are used unless the configuration or --timeout set others
+var DefaultTimeouts = Timeouts{
+	Connect:   10 * time.Second,
+	FirstByte: 2 * time.Minute,
+	Total:     10 * time.Minute,
+}
+
+// newHTTPClient returns a client applying the connect and first-byte
+// timeouts. The total timeout is left to the request's context so that a
+// long streamed answer is not cut off by the client.
+func newHTTPClient(timeouts Timeouts) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeouts.Connect
+	transport.ResponseHeaderTimeout = timeouts.FirstByte
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ModelLister is implemented by providers that can list their models
+type ModelLister interface {
+	// Models returns the names of the models the API offers
+	Models(ctx context.Context) ([]string, error)
+}
+
+// getJSON sends a GET request and decodes the JSON response into v
+func getJSON(ctx context.Context, client *http.Client, provider, url string, header http.Header, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(provider, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// nonChatModels are parts of OpenAI model names that cannot chat
+var nonChatModels = []string{"embedding", "whisper", "tts", "dall-e", "davinci", "babbage", "audio", "realtime", "transcribe", "image", "moderation", "search"}
+
+func (p *openAIProvider) Models(ctx context.Context) ([]string, error) {
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	header := http.Header{}
+	if p.apiKey != "" {
+		header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if err := getJSON(ctx, p.client, "OpenAI", endpoint(p.baseURL, openAIBaseURL, "/models"), header, &list); err != nil {
+		return nil, err
+	}
+
+	var models []string
+	for _, model := range list.Data {
+		// The OpenAI API also lists embedding, audio and image models.
+		// Other servers are assumed to list what they can serve.
+		if p.baseURL == "" && !isOpenAIChatModel(model.ID) {
+			continue
+		}
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
+
+// isOpenAIChatModel reports whether an OpenAI model can be used for chat
+func isOpenAIChatModel(id string) bool {
+	if !strings.HasPrefix(id, "gpt-") && !strings.HasPrefix(id, "chatgpt-") && !strings.HasPrefix(id, "o1") && !strings.HasPrefix(id, "o3") && !strings.HasPrefix(id, "o4") {
+		return false
+	}
+	for _, part := range nonChatModels {
+		if strings.Contains(id, part) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *anthropicProvider) Models(ctx context.Context) ([]string, error) {
+	header := http.Header{}
+	header.Set("x-api-key", p.apiKey)
+	header.Set("anthropic-version", anthropicVersion)
+
+	var models []string
+	after := ""
+	for {
+		query := url.Values{"limit": {"1000"}}
+		if after != "" {
+			query.Set("after_id", after)
+		}
+		var page struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := getJSON(ctx, p.client, "Anthropic", endpoint(p.baseURL, anthropicBaseURL, "/v1/models?"+query.Encode()), header, &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Data {
+			models = append(models, model.ID)
+		}
+		if !page.HasMore || page.LastID == "" || page.LastID == after {
+			return models, nil
+		}
+		after = page.LastID
+	}
+}
+
+func (p *ollamaProvider) Models(ctx context.Context) ([]string, error) {
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	header := http.Header{}
+	if p.apiKey != "" {
+		header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if err := getJSON(ctx, p.client, "Ollama", endpoint(p.baseURL, ollamaBaseURL, "/api/tags"), header, &tags); err != nil {
+		return nil, err
+	}
+
+	var models []string
+	for _, model := range tags.Models {
+		// "llama3.2:latest" is what "llama3.2" refers to
+		models = append(models, strings.TrimSuffix(model.Name, ":latest"))
+	}
+	return models, nil
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ask/config"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+type ollamaProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+type ollamaRequest struct {
+	Model    string               `json:"model"`
+	Messages []config.ChatMessage `json:"messages"`
+	// Stream has no omitempty since Ollama streams unless told otherwise
+	Stream  bool          `json:"stream"`
+	Options ollamaOptions `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       int      `json:"num_predict,omitempty"` // longest answer
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+}
+
+// ollamaResponse is both the complete response and a single line of a
+// streamed (newline-delimited JSON) response
+type ollamaResponse struct {
+	Message    config.ChatMessage `json:"message"`
+	Done       bool               `json:"done"`
+	DoneReason string             `json:"done_reason"`
+	Error      string             `json:"error"`
+	// Token counts, reported when done
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+// result returns the final response described by the last chunk
+func (r *ollamaResponse) result(content string) *Response {
+	resp := &Response{Content: content, FinishReason: r.DoneReason}
+	if r.PromptEvalCount > 0 || r.EvalCount > 0 {
+		resp.Usage = &Usage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		}
+	}
+	return resp
+}
+
+func newOllama(settings Settings) Provider {
+	return &ollamaProvider{apiKey: settings.APIKey, baseURL: settings.BaseURL, client: newHTTPClient(settings.Timeouts)}
+}
+
+func (p *ollamaProvider) Name() string {
+	return config.ProviderOllama
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error) {
+	stream := onDelta != nil
+	body, err := json.Marshal(ollamaRequest{
+		Model:    req.Model,
+		Messages: withoutTools(req.Messages),
+		Stream:   stream,
+		Options: ollamaOptions{
+			Temperature:      req.Temperature,
+			TopP:             req.TopP,
+			NumPredict:       req.MaxTokens,
+			PresencePenalty:  req.PresencePenalty,
+			FrequencyPenalty: req.FrequencyPenalty,
+			Seed:             req.Seed,
+			Stop:             req.Stop,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint(p.baseURL, ollamaBaseURL, "/api/chat"), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("Ollama", resp)
+	}
+
+	if stream {
+		result, err := readOllamaStream(resp.Body, onDelta)
+		if err != nil {
+			return result, fmt.Errorf("failed to read streamed response: %w", err)
+		}
+		return result, nil
+	}
+
+	var chatResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if chatResp.Error != "" {
+		return nil, fmt.Errorf("Ollama API error: %s", chatResp.Error)
+	}
+	return chatResp.result(chatResp.Message.Content), nil
+}
+
+// readOllamaStream reads a newline-delimited JSON stream, calling onDelta
+// with each piece of text, and returns the full response
+func readOllamaStream(r io.Reader, onDelta func(string)) (*Response, error) {
+	var full strings.Builder
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			var chunk ollamaResponse
+			if jsonErr := json.Unmarshal(line, &chunk); jsonErr != nil {
+				return &Response{Content: full.String()}, fmt.Errorf("failed to decode stream chunk: %v", jsonErr)
+			}
+			if chunk.Error != "" {
+				return &Response{Content: full.String()}, fmt.Errorf("Ollama API error: %s", chunk.Error)
+			}
+			if chunk.Message.Content != "" {
+				full.WriteString(chunk.Message.Content)
+				onDelta(chunk.Message.Content)
+			}
+			if chunk.Done {
+				return chunk.result(full.String()), nil
+			}
+		}
+
+		if err == io.EOF {
+			return &Response{Content: full.String()}, nil
+		}
+		if err != nil {
+			return &Response{Content: full.String()}, err
+		}
+	}
+}
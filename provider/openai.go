@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ask/config"
+)
+
+const openAIBaseURL = "https://api.openai.com/v1"
+
+type openAIProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+type openAIRequest struct {
+	Model       string               `json:"model"`
+	Messages    []config.ChatMessage `json:"messages"`
+	Temperature *float64             `json:"temperature,omitempty"`
+	TopP        *float64             `json:"top_p,omitempty"`
+	// MaxTokens is deprecated by OpenAI in favor of MaxCompletionTokens,
+	// which reasoning models require, but compatible servers expect it
+	MaxTokens           int                  `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                  `json:"max_completion_tokens,omitempty"`
+	PresencePenalty     *float64             `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64             `json:"frequency_penalty,omitempty"`
+	Seed                *int64               `json:"seed,omitempty"`
+	Stop                []string             `json:"stop,omitempty"`
+	ReasoningEffort     string               `json:"reasoning_effort,omitempty"`
+	Stream              bool                 `json:"stream,omitempty"`
+	StreamOptions       *openAIStreamOptions `json:"stream_options,omitempty"`
+	Tools               []Tool               `json:"tools,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      config.ChatMessage `json:"message"`
+		FinishReason string             `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// openAIStreamChunk is a single server-sent event of a streamed chat completion
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string                `json:"content"`
+			ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"` // only in the last chunk, when requested
+}
+
+// openAIToolCallDelta is a piece of a streamed tool call. The arguments
+// arrive in fragments for the call at Index.
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+func newOpenAI(settings Settings) Provider {
+	return &openAIProvider{apiKey: settings.APIKey, baseURL: settings.BaseURL, client: newHTTPClient(settings.Timeouts)}
+}
+
+func (p *openAIProvider) Name() string {
+	return config.ProviderOpenAI
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error) {
+	stream := onDelta != nil
+	openAIReq := openAIRequest{
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		Stop:             req.Stop,
+		ReasoningEffort:  req.ReasoningEffort,
+		Stream:           stream,
+		Tools:            req.Tools,
+	}
+	if p.baseURL == "" {
+		openAIReq.MaxCompletionTokens = req.MaxTokens
+	} else {
+		openAIReq.MaxTokens = req.MaxTokens
+	}
+	// Streamed responses only report usage when asked to. Not every
+	// OpenAI-compatible server accepts the option, so it is only sent to
+	// the OpenAI API itself.
+	if stream && p.baseURL == "" {
+		openAIReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	body, err := json.Marshal(openAIReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint(p.baseURL, openAIBaseURL, "/chat/completions"), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("OpenAI", resp)
+	}
+
+	if stream {
+		var full strings.Builder
+		result := &Response{}
+		err := readSSE(resp.Body, func(data string) (bool, error) {
+			if data == "[DONE]" {
+				return true, nil
+			}
+			var chunk openAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return false, fmt.Errorf("failed to decode stream chunk: %v", err)
+			}
+			if chunk.Usage != nil {
+				result.Usage = chunk.Usage
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != "" {
+					result.FinishReason = choice.FinishReason
+				}
+				for _, delta := range choice.Delta.ToolCalls {
+					for len(result.ToolCalls) <= delta.Index {
+						result.ToolCalls = append(result.ToolCalls, config.ToolCall{Type: "function"})
+					}
+					call := &result.ToolCalls[delta.Index]
+					if delta.ID != "" {
+						call.ID = delta.ID
+					}
+					call.Function.Name += delta.Function.Name
+					call.Function.Arguments += delta.Function.Arguments
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				full.WriteString(choice.Delta.Content)
+				onDelta(choice.Delta.Content)
+			}
+			return false, nil
+		})
+		result.Content = full.String()
+		if err != nil {
+			return result, fmt.Errorf("failed to read streamed response: %w", err)
+		}
+		return result, nil
+	}
+
+	var chatResp openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return &Response{Usage: chatResp.Usage}, nil
+	}
+	return &Response{
+		Content:      chatResp.Choices[0].Message.Content,
+		FinishReason: chatResp.Choices[0].FinishReason,
+		Usage:        chatResp.Usage,
+		ToolCalls:    chatResp.Choices[0].Message.ToolCalls,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ask/config"
+)
+
+// Request is a chat request independent of any backend's wire format
+type Request struct {
+	Model    string
+	Messages []config.ChatMessage
+	config.Sampling
+	Tools []Tool // functions the model may call
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string       `json:"type"` // always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON schema of a function
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// Response is the answer returned by a backend
+type Response struct {
+	Content      string
+	FinishReason string // why generation stopped, e.g. "stop" or "length"
+	Usage        *Usage // nil when the backend did not report it
+	ToolCalls    []config.ToolCall
+}
+
+// Usage is the number of tokens used by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Provider sends chat requests to a model backend
+type Provider interface {
+	// Name returns the provider name as used in the configuration
+	Name() string
+
+	// Chat sends req and returns the complete answer. If onDelta is not nil
+	// the answer is streamed and onDelta is called with each piece of text
+	// as it arrives.
+	Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error)
+}
+
+// Settings holds the connection settings for a provider
+type Settings struct {
+	APIKey string
+	// BaseURL overrides the provider's default endpoint, e.g. to target a
+	// local OpenAI-compatible server
+	BaseURL  string
+	Timeouts Timeouts
+}
+
+type factory func(settings Settings) Provider
+
+var providers = map[string]factory{
+	config.ProviderOpenAI:    newOpenAI,
+	config.ProviderAnthropic: newAnthropic,
+	config.ProviderOllama:    newOllama,
+}
+
+// New returns the provider registered under name
+func New(name string, settings Settings) (Provider, error) {
+	create, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s (available: %v)", name, Names())
+	}
+	return create(settings), nil
+}
+
+// withoutTools removes tool calls and their results from messages, for
+// backends without tool support. The final answers are kept.
+func withoutTools(messages []config.ChatMessage) []config.ChatMessage {
+	var out []config.ChatMessage
+	for _, msg := range messages {
+		if msg.Role == "tool" || (len(msg.ToolCalls) > 0 && msg.Content == "") {
+			continue
+		}
+		msg.ToolCalls = nil
+		out = append(out, msg)
+	}
+	return out
+}
+
+// endpoint joins a base URL, falling back to def when empty, with a path
+func endpoint(baseURL, def, path string) string {
+	if baseURL == "" {
+		baseURL = def
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
+
+// Names returns the names of all available providers
+func Names() []string {
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how failed requests are retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts in total, 1 disables retries
+	Deadline    time.Duration // total time for all attempts, zero for no limit
+	BaseDelay   time.Duration // delay before the first retry, doubled each time
+	MaxDelay    time.Duration // longest delay between attempts
+}
+
+// DefaultRetryPolicy is used unless the configuration sets another
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	Deadline:    2 * time.Minute,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// Retry describes a retry that is about to happen
+type Retry struct {
+	Attempt     int // the attempt that failed, counting from 1
+	MaxAttempts int
+	Delay       time.Duration
+	Err         error
+}
+
+// retrying is a Provider that retries temporary failures of another
+type retrying struct {
+	Provider
+	policy  RetryPolicy
+	onRetry func(Retry)
+}
+
+// WithRetry returns p retrying rate limits, server errors and network
+// errors with jittered exponential backoff. onRetry, if not nil, is called
+// before waiting for each retry. A streamed answer is only retried if none
+// of it has been received.
+func WithRetry(p Provider, policy RetryPolicy, onRetry func(Retry)) Provider {
+	if policy.MaxAttempts <= 1 {
+		return p
+	}
+	return &retrying{Provider: p, policy: policy, onRetry: onRetry}
+}
+
+func (r *retrying) Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error) {
+	start := time.Now()
+	received := false
+	var wrapped func(string)
+	if onDelta != nil {
+		wrapped = func(text string) {
+			received = true
+			onDelta(text)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := r.Provider.Chat(ctx, req, wrapped)
+		if err == nil || received || attempt >= r.policy.MaxAttempts || ctx.Err() != nil || !isTemporary(err) {
+			return resp, err
+		}
+
+		// A wait past the deadline is cut short for a last attempt
+		delay := r.delay(attempt, err)
+		if r.policy.Deadline > 0 {
+			left := r.policy.Deadline - time.Since(start)
+			if left <= 0 {
+				return resp, err
+			}
+			if delay > left {
+				delay = left
+			}
+		}
+		if r.onRetry != nil {
+			r.onRetry(Retry{Attempt: attempt, MaxAttempts: r.policy.MaxAttempts, Delay: delay, Err: err})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait after a failed attempt: what the API
+// asked for, else an exponential backoff with jitter, at most MaxDelay
+func (r *retrying) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if r.policy.MaxDelay > 0 && apiErr.RetryAfter > r.policy.MaxDelay {
+			return r.policy.MaxDelay
+		}
+		return apiErr.RetryAfter
+	}
+
+	backoff := r.policy.BaseDelay << uint(attempt-1)
+	if backoff <= 0 || (r.policy.MaxDelay > 0 && backoff > r.policy.MaxDelay) {
+		backoff = r.policy.MaxDelay
+	}
+	// Wait between half and all of the backoff so that clients limited at
+	// the same time do not retry together
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isTemporary reports whether err may not happen again
+func isTemporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.IsTemporary()
+	}
+	return IsNetworkError(err)
+}
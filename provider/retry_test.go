@@ -0,0 +1,306 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries without noticeable waits
+var fastRetries = RetryPolicy{MaxAttempts: 4, Deadline: 10 * time.Second, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// failingServer answers with the given failures, one per request, and then
+// with a successful chat completion. It returns the server and the number
+// of requests it received.
+func failingServer(t *testing.T, failures ...func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n <= len(failures) {
+			failures[n-1](w)
+			return
+		}
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// status returns a failure with an OpenAI style error body and headers
+func status(code int, errorCode string, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":{"message":"failure %d","type":"error","code":%q}}`, code, errorCode)
+	}
+}
+
+func openAIAt(srv *httptest.Server) Provider {
+	return newOpenAI(Settings{BaseURL: srv.URL, Timeouts: DefaultTimeouts})
+}
+
+func TestWithRetrySucceedsAfterTemporaryErrors(t *testing.T) {
+	srv, requests := failingServer(t,
+		status(http.StatusTooManyRequests, "rate_limit_exceeded", "Retry-After", "0.02"),
+		status(http.StatusServiceUnavailable, "", "X-Ratelimit-Remaining-Requests", "0", "X-Ratelimit-Reset-Requests", "30ms"),
+		status(http.StatusBadGateway, ""),
+	)
+	var retries []Retry
+	policy := fastRetries
+	policy.MaxDelay = 50 * time.Millisecond
+	p := WithRetry(openAIAt(srv), policy, func(r Retry) { retries = append(retries, r) })
+
+	resp, err := p.Chat(context.Background(), &Request{Model: "gpt-4o"}, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "Hello" || *requests != 4 {
+		t.Errorf("got %q after %d requests, want Hello after 4", resp.Content, *requests)
+	}
+	if len(retries) != 3 {
+		t.Fatalf("onRetry called %d times, want 3", len(retries))
+	}
+	// The waits the API asked for are used as they are
+	if retries[0].Delay != 20*time.Millisecond || retries[1].Delay != 30*time.Millisecond {
+		t.Errorf("delays = %v, %v; want the Retry-After and reset header values", retries[0].Delay, retries[1].Delay)
+	}
+	if retries[2].Delay > policy.MaxDelay || retries[2].Attempt != 3 || retries[2].MaxAttempts != 4 {
+		t.Errorf("third retry = %+v, want a backoff of at most %v", retries[2], policy.MaxDelay)
+	}
+}
+
+func TestWithRetryCapsRequestedDelay(t *testing.T) {
+	srv, requests := failingServer(t, status(http.StatusTooManyRequests, "", "Retry-After", "60"))
+	var retries []Retry
+	p := WithRetry(openAIAt(srv), fastRetries, func(r Retry) { retries = append(retries, r) })
+
+	if _, err := p.Chat(context.Background(), &Request{Model: "gpt-4o"}, nil); err != nil || *requests != 2 {
+		t.Fatalf("got %v after %d requests, want success after 2", err, *requests)
+	}
+	if len(retries) != 1 || retries[0].Delay != fastRetries.MaxDelay {
+		t.Errorf("retries = %+v, want one waiting MaxDelay", retries)
+	}
+}
+
+func TestWithRetryStreamedBeforeData(t *testing.T) {
+	srv, requests := failingServer(t, status(http.StatusServiceUnavailable, ""))
+	p := WithRetry(openAIAt(srv), fastRetries, nil)
+
+	var streamed string
+	resp, err := p.Chat(context.Background(), &Request{Model: "gpt-4o"}, func(text string) { streamed += text })
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if streamed != "Hello" || resp.Content != "Hello" || *requests != 2 {
+		t.Errorf("streamed %q, content %q after %d requests", streamed, resp.Content, *requests)
+	}
+}
+
+func TestWithRetryPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure func(w http.ResponseWriter)
+	}{
+		{"bad request", status(http.StatusBadRequest, "invalid_request")},
+		{"unauthorized", status(http.StatusUnauthorized, "invalid_api_key")},
+		{"exhausted quota", status(http.StatusTooManyRequests, "insufficient_quota")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := failingServer(t, test.failure)
+			p := WithRetry(openAIAt(srv), fastRetries, nil)
+			_, err := p.Chat(context.Background(), &Request{Model: "gpt-4o"}, nil)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || *requests != 1 {
+				t.Errorf("got %v after %d requests, want an API error after 1", err, *requests)
+			}
+		})
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	unavailable := status(http.StatusServiceUnavailable, "")
+	srv, requests := failingServer(t, unavailable, unavailable, unavailable, unavailable, unavailable)
+	p := WithRetry(openAIAt(srv), fastRetries, nil)
+
+	_, err := p.Chat(context.Background(), &Request{Model: "gpt-4o"}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("error = %v, want the last 503", err)
+	}
+	if int(*requests) != fastRetries.MaxAttempts {
+		t.Errorf("%d requests, want %d", *requests, fastRetries.MaxAttempts)
+	}
+}
+
+func TestWithRetryDeadline(t *testing.T) {
+	limited := status(http.StatusTooManyRequests, "", "Retry-After", "60")
+	policy := fastRetries
+	policy.Deadline = 200 * time.Millisecond
+	policy.MaxDelay = time.Minute
+
+	// A wait past the deadline is cut short for one last attempt
+	srv, requests := failingServer(t, limited)
+	start := time.Now()
+	_, err := WithRetry(openAIAt(srv), policy, nil).Chat(context.Background(), &Request{Model: "gpt-4o"}, nil)
+	elapsed := time.Since(start)
+	if err != nil || *requests != 2 || elapsed < policy.Deadline/2 || elapsed > time.Second {
+		t.Errorf("got %v after %d requests in %v, want success at the deadline", err, *requests, elapsed)
+	}
+
+	// After that attempt fails too, it gives up
+	srv, requests = failingServer(t, limited, limited, limited)
+	start = time.Now()
+	_, err = WithRetry(openAIAt(srv), policy, nil).Chat(context.Background(), &Request{Model: "gpt-4o"}, nil)
+	if err == nil || *requests != 2 || time.Since(start) > time.Second {
+		t.Errorf("got %v after %d requests in %v, want the error at the deadline", err, *requests, time.Since(start))
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+	srv, _ := failingServer(t, status(http.StatusTooManyRequests, "", "Retry-After", "5"))
+	policy := fastRetries
+	policy.MaxDelay = time.Minute
+	p := WithRetry(openAIAt(srv), policy, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Chat(ctx, &Request{Model: "gpt-4o"}, nil); err == nil {
+		t.Error("Chat succeeded after being cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Chat returned after %v, want it to stop waiting when cancelled", elapsed)
+	}
+}
+
+// flakyStream streams a piece of the answer and then fails temporarily
+type flakyStream struct {
+	calls int
+}
+
+func (f *flakyStream) Name() string { return "flaky" }
+
+func (f *flakyStream) Chat(ctx context.Context, req *Request, onDelta func(string)) (*Response, error) {
+	f.calls++
+	if onDelta != nil {
+		onDelta("partial ")
+	}
+	return &Response{Content: "partial "}, &APIError{Provider: "Flaky", StatusCode: http.StatusServiceUnavailable}
+}
+
+func TestWithRetryNotAfterStreamStarted(t *testing.T) {
+	flaky := &flakyStream{}
+	p := WithRetry(flaky, fastRetries, nil)
+
+	var streamed string
+	resp, err := p.Chat(context.Background(), &Request{}, func(text string) { streamed += text })
+	if err == nil || flaky.calls != 1 {
+		t.Errorf("got %v after %d calls, want the error after 1", err, flaky.calls)
+	}
+	// The partial answer is returned so that it can be saved
+	if streamed != "partial " || resp == nil || resp.Content != "partial " {
+		t.Errorf("streamed %q, response %+v", streamed, resp)
+	}
+
+	// Without streaming the same failure is retried
+	flaky.calls = 0
+	p.Chat(context.Background(), &Request{}, nil)
+	if flaky.calls != fastRetries.MaxAttempts {
+		t.Errorf("%d calls without streaming, want %d", flaky.calls, fastRetries.MaxAttempts)
+	}
+}
+
+func TestWithRetryDisabled(t *testing.T) {
+	flaky := &flakyStream{}
+	if p := WithRetry(flaky, RetryPolicy{MaxAttempts: 1}, nil); p != Provider(flaky) {
+		t.Error("WithRetry wrapped the provider with retries disabled")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{"fractional seconds", map[string]string{"Retry-After": "0.5"}, 500 * time.Millisecond},
+		{"HTTP date", map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second},
+		{"past HTTP date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+		{"invalid", map[string]string{"Retry-After": "soon"}, 0},
+		{"OpenAI reset duration", map[string]string{"X-Ratelimit-Remaining-Requests": "0", "X-Ratelimit-Reset-Requests": "6m0s"}, 6 * time.Minute},
+		{"reset without remaining", map[string]string{"X-Ratelimit-Reset-Requests": "6m0s"}, 0},
+		{"only the exhausted limit", map[string]string{
+			"X-Ratelimit-Remaining-Requests": "0", "X-Ratelimit-Reset-Requests": "20ms",
+			"X-Ratelimit-Remaining-Tokens": "5000", "X-Ratelimit-Reset-Tokens": "6m0s",
+		}, 20 * time.Millisecond},
+		{"exhausted tokens", map[string]string{
+			"X-Ratelimit-Remaining-Requests": "99", "X-Ratelimit-Reset-Requests": "20ms",
+			"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "1.5s",
+		}, 1500 * time.Millisecond},
+		{"longest exhausted reset", map[string]string{
+			"X-Ratelimit-Remaining-Requests": "0", "X-Ratelimit-Reset-Requests": "20ms",
+			"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "1.5s",
+		}, 1500 * time.Millisecond},
+		{"Anthropic RFC 3339 reset", map[string]string{
+			"Anthropic-Ratelimit-Requests-Remaining": "0", "Anthropic-Ratelimit-Requests-Reset": now.Add(42 * time.Second).Format(time.RFC3339),
+			"Anthropic-Ratelimit-Tokens-Remaining": "80000", "Anthropic-Ratelimit-Tokens-Reset": now.Add(time.Hour).Format(time.RFC3339),
+		}, 42 * time.Second},
+		{"Anthropic input tokens", map[string]string{
+			"Anthropic-Ratelimit-Input-Tokens-Remaining": "0", "Anthropic-Ratelimit-Input-Tokens-Reset": now.Add(7 * time.Second).Format(time.RFC3339),
+		}, 7 * time.Second},
+		{"Unix time reset", map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": fmt.Sprint(now.Add(10 * time.Second).Unix())}, 10 * time.Second},
+		{"Retry-After wins", map[string]string{"Retry-After": "2", "X-Ratelimit-Remaining-Requests": "0", "X-Ratelimit-Reset-Requests": "1m"}, 2 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range test.headers {
+				header.Set(name, value)
+			}
+			if got := retryAfter(header, now); got != test.want {
+				t.Errorf("retryAfter = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorParsing(t *testing.T) {
+	tests := []struct {
+		body      string
+		message   string
+		code      string
+		temporary bool
+	}{
+		{`{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`, "Rate limit reached", "rate_limit_exceeded", true},
+		{`{"error":{"message":"You exceeded your quota","type":"insufficient_quota","code":null}}`, "You exceeded your quota", "", false},
+		{`{"error":"model is loading"}`, "model is loading", "", true},
+		{`{"error":{"message":"Overloaded","code":529}}`, "Overloaded", "529", true},
+	}
+	for _, test := range tests {
+		e := &APIError{Provider: "Test", StatusCode: http.StatusTooManyRequests, Body: test.body}
+		e.parseBody([]byte(test.body))
+		if e.Message != test.message || e.Code != test.code || e.IsTemporary() != test.temporary {
+			t.Errorf("%s: message %q, code %q, temporary %v", test.body, e.Message, e.Code, e.IsTemporary())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readSSE reads a server-sent event stream and calls fn with the payload of
+// every data line. Reading stops when fn returns done or an error, or when
+// the stream ends.
+func readSSE(r io.Reader, fn func(data string) (done bool, err error)) error {
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, "data:") {
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			done, fnErr := fn(data)
+			if fnErr != nil {
+				return fnErr
+			}
+			if done {
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			// Some servers close the connection without a final event
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
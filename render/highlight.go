@@ -0,0 +1,285 @@
+package render
+
+import (
+	"strings"
+)
+
+// syntax describes enough of a language to highlight it
+type syntax struct {
+	keywords     []string
+	literals     []string // true, nil, None, ...
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+	ignoreCase   bool
+}
+
+var (
+	cLike = syntax{
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+
+	syntaxes = map[string]syntax{
+		"go": withKeywords(cLike, "`\"'",
+			"break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var",
+			"true false nil iota"),
+		"python": {
+			keywords:     fields("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield"),
+			literals:     fields("True False None self"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"javascript": withKeywords(cLike, "\"'`",
+			"async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield",
+			"true false null undefined NaN"),
+		"typescript": withKeywords(cLike, "\"'`",
+			"abstract as async await break case catch class const continue declare default delete do else enum export extends finally for from function if implements import in instanceof interface let namespace new of private protected public readonly return static super switch this throw try type typeof var void while yield",
+			"true false null undefined"),
+		"java": withKeywords(cLike, `"'`,
+			"abstract assert break case catch class const continue default do else enum extends final finally for if implements import instanceof interface native new package private protected public return static super switch synchronized this throw throws try var void volatile while",
+			"true false null"),
+		"c": withKeywords(cLike, `"'`,
+			"auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while #include #define #ifdef #ifndef #endif",
+			"NULL true false"),
+		"cpp": withKeywords(cLike, `"'`,
+			"auto bool break case catch char class const constexpr continue default delete do double else enum explicit extern float for friend if inline int long namespace new operator private protected public return short signed sizeof static struct switch template this throw try typedef typename union unsigned using virtual void volatile while #include #define",
+			"true false nullptr NULL"),
+		"csharp": withKeywords(cLike, `"'`,
+			"abstract as async await base bool break case catch class const continue default do double else enum event false finally for foreach if in int interface internal is namespace new object out override private protected public readonly ref return sealed static string struct switch this throw try using var virtual void while",
+			"true false null"),
+		"rust": withKeywords(cLike, `"`,
+			"as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while",
+			"true false None Some Ok Err"),
+		"swift": withKeywords(cLike, `"`,
+			"as break case catch class continue default defer do else enum extension fileprivate for func guard if import in init internal let private protocol public return self static struct switch throw throws try var where while",
+			"true false nil"),
+		"kotlin": withKeywords(cLike, `"'`,
+			"as break class continue do else fun for if import in interface is object package private protected public return super this throw try typealias val var when while",
+			"true false null"),
+		"php": {
+			keywords:     fields("abstract array as break case catch class const continue default do echo else elseif extends final for foreach function if implements include interface namespace new private protected public require return static switch throw try use while"),
+			literals:     fields("true false null"),
+			lineComments: []string{"//", "#"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"ruby": {
+			keywords:     fields("alias and begin break case class def defined? do else elsif end ensure for if in module next not or redo rescue retry return self super then undef unless until when while yield require attr_accessor"),
+			literals:     fields("true false nil"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"bash": {
+			keywords:     fields("if then else elif fi case esac for while until do done in function return local export readonly declare set unset shift exit echo source alias cd"),
+			literals:     fields("true false"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"powershell": {
+			keywords:     fields("begin break catch class continue do else elseif end exit filter finally for foreach function if in param process return switch throw trap try until while"),
+			literals:     fields("$true $false $null"),
+			lineComments: []string{"#"},
+			blockComment: [2]string{"<#", "#>"},
+			quotes:       `"'`,
+			ignoreCase:   true,
+		},
+		"sql": {
+			keywords:     fields("select from where and or not in is like between join inner left right outer full on as group by order having limit offset insert into values update set delete create table index view drop alter add column primary key foreign references unique distinct union all case when then else end with returning begin commit rollback default constraint exists"),
+			literals:     fields("null true false"),
+			lineComments: []string{"--"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `'"`,
+			ignoreCase:   true,
+		},
+		"yaml": {
+			literals:     fields("true false null yes no on off"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"toml": {
+			literals:     fields("true false"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"json": {
+			literals: fields("true false null"),
+			quotes:   `"`,
+		},
+		"dockerfile": {
+			keywords:     fields("FROM RUN CMD LABEL EXPOSE ENV ADD COPY ENTRYPOINT VOLUME USER WORKDIR ARG ONBUILD STOPSIGNAL HEALTHCHECK SHELL AS"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"makefile": {
+			keywords:     fields("ifeq ifneq ifdef ifndef else endif include define endef export .PHONY"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"lua": {
+			keywords:     fields("and break do else elseif end for function goto if in local not or repeat return then until while"),
+			literals:     fields("true false nil"),
+			lineComments: []string{"--"},
+			quotes:       `"'`,
+		},
+	}
+
+	aliases = map[string]string{
+		"golang": "go", "py": "python", "python3": "python", "js": "javascript",
+		"jsx": "javascript", "node": "javascript", "ts": "typescript", "tsx": "typescript",
+		"h": "c", "c++": "cpp", "cc": "cpp", "hpp": "cpp", "cs": "csharp", "c#": "csharp",
+		"rs": "rust", "kt": "kotlin", "rb": "ruby", "sh": "bash", "shell": "bash",
+		"zsh": "bash", "console": "bash", "ps1": "powershell", "pwsh": "powershell",
+		"postgres": "sql", "postgresql": "sql", "mysql": "sql", "sqlite": "sql",
+		"yml": "yaml", "jsonc": "json", "docker": "dockerfile", "make": "makefile",
+		"scala": "java", "groovy": "java", "dart": "java",
+	}
+)
+
+func fields(s string) []string {
+	return strings.Fields(s)
+}
+
+func withKeywords(base syntax, quotes, keywords, literals string) syntax {
+	base.quotes = quotes
+	base.keywords = fields(keywords)
+	base.literals = fields(literals)
+	return base
+}
+
+// highlighter colors the lines of one code block
+type highlighter struct {
+	syntax   *syntax
+	keywords map[string]bool
+	literals map[string]bool
+	comment  bool // inside a block comment
+}
+
+// newHighlighter returns a highlighter for lang. Unknown languages are
+// printed without colors.
+func newHighlighter(lang string) *highlighter {
+	lang = strings.ToLower(lang)
+	if alias, ok := aliases[lang]; ok {
+		lang = alias
+	}
+	s, ok := syntaxes[lang]
+	if !ok {
+		return &highlighter{}
+	}
+
+	h := &highlighter{syntax: &s, keywords: map[string]bool{}, literals: map[string]bool{}}
+	for _, k := range s.keywords {
+		h.keywords[h.fold(k)] = true
+	}
+	for _, l := range s.literals {
+		h.literals[h.fold(l)] = true
+	}
+	return h
+}
+
+func (h *highlighter) fold(word string) string {
+	if h.syntax.ignoreCase {
+		return strings.ToLower(word)
+	}
+	return word
+}
+
+// line returns a code line with syntax colors
+func (h *highlighter) line(line string) string {
+	if h.syntax == nil {
+		return line
+	}
+	s := h.syntax
+
+	var out strings.Builder
+	i := 0
+	for i < len(line) {
+		if h.comment {
+			end := strings.Index(line[i:], s.blockComment[1])
+			if end < 0 {
+				out.WriteString(gray + line[i:] + reset)
+				return out.String()
+			}
+			end += i + len(s.blockComment[1])
+			out.WriteString(gray + line[i:end] + reset)
+			h.comment = false
+			i = end
+			continue
+		}
+
+		rest := line[i:]
+		if s.blockComment[0] != "" && strings.HasPrefix(rest, s.blockComment[0]) {
+			h.comment = true
+			out.WriteString(gray + s.blockComment[0])
+			i += len(s.blockComment[0])
+			out.WriteString(reset)
+			continue
+		}
+		if h.isLineComment(line, i) {
+			out.WriteString(gray + rest + reset)
+			return out.String()
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(s.quotes, c) >= 0:
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end < len(line) {
+				end++
+			} else {
+				end = len(line)
+			}
+			out.WriteString(green + line[i:end] + reset)
+			i = end
+		case c >= '0' && c <= '9' && (i == 0 || !isWordByte(line[i-1])):
+			end := i
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			out.WriteString(cyan + line[i:end] + reset)
+			i = end
+		case isWordByte(c) || c == '#' || c == '$' || c == '.':
+			end := i + 1
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '?') {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case h.keywords[h.fold(word)]:
+				out.WriteString(magenta + word + reset)
+			case h.literals[h.fold(word)]:
+				out.WriteString(cyan + word + reset)
+			default:
+				out.WriteString(word)
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// isLineComment reports whether a line comment starts at line[i]. A # only
+// starts a comment at the start of a word, so that $# or a#b are not
+// comments in shell code.
+func (h *highlighter) isLineComment(line string, i int) bool {
+	for _, marker := range h.syntax.lineComments {
+		if !strings.HasPrefix(line[i:], marker) {
+			continue
+		}
+		if marker == "#" && i > 0 && line[i-1] != ' ' && line[i-1] != '\t' {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+)
+
+const strike = "\x1b[9m"
+
+var link = regexp.MustCompile(`^\[([^\]]+)\]\(([^)\s]+)\)`)
+
+// inline renders emphasis, code spans and links in text. base is the style
+// in effect around the text, restored after each styled span.
+func inline(text, base string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#+-.!|~", text[i+1]) >= 0:
+			out.WriteByte(text[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			ticks := run(text[i:], '`')
+			if end := strings.Index(text[i+ticks:], strings.Repeat("`", ticks)); end >= 0 {
+				code := strings.TrimSpace(text[i+ticks : i+ticks+end])
+				out.WriteString(yellow + code + reset + base)
+				i += ticks + end + ticks
+				continue
+			}
+
+		case c == '[':
+			if m := link.FindStringSubmatch(text[i:]); m != nil {
+				out.WriteString(underline + inline(m[1], base+underline) + reset + base)
+				if m[1] != m[2] {
+					out.WriteString(gray + " (" + m[2] + ")" + reset + base)
+				}
+				i += len(m[0])
+				continue
+			}
+
+		case c == '~' && strings.HasPrefix(text[i:], "~~"):
+			if inner, n := delimited(text, i, "~~"); n > 0 {
+				out.WriteString(strike + inline(inner, base+strike) + reset + base)
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			// Underscores inside words, as in snake_case, are not emphasis
+			if c == '_' && i > 0 && isWordByte(text[i-1]) {
+				break
+			}
+			delim := string(c)
+			style := italic
+			if strings.HasPrefix(text[i:], delim+delim) {
+				delim += delim
+				style = bold
+			}
+			if inner, n := delimited(text, i, delim); n > 0 {
+				if c == '_' && i+n < len(text) && isWordByte(text[i+n]) {
+					break
+				}
+				out.WriteString(style + inline(inner, base+style) + reset + base)
+				i += n
+				continue
+			}
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// delimited finds the text between delim at text[start:] and the next
+// closing delim. It returns the inner text and the length of the whole span,
+// or 0 when there is no valid span.
+func delimited(text string, start int, delim string) (string, int) {
+	open := start + len(delim)
+	if open >= len(text) || text[open] == ' ' {
+		return "", 0
+	}
+	for j := open + 1; j+len(delim) <= len(text); j++ {
+		if !strings.HasPrefix(text[j:], delim) || text[j-1] == ' ' {
+			continue
+		}
+		// A single * must not be the start of a **
+		if len(delim) == 1 && j+1 < len(text) && text[j+1] == delim[0] {
+			j++
+			continue
+		}
+		return text[open:j], j + len(delim) - start
+	}
+	return "", 0
+}
+
+// run counts the repeats of c at the start of s
+func run(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,151 @@
+package render
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ANSI styles
+const (
+	reset     = "\x1b[0m"
+	bold      = "\x1b[1m"
+	dim       = "\x1b[2m"
+	italic    = "\x1b[3m"
+	underline = "\x1b[4m"
+	green     = "\x1b[32m"
+	yellow    = "\x1b[33m"
+	magenta   = "\x1b[35m"
+	cyan      = "\x1b[36m"
+	gray      = "\x1b[90m"
+)
+
+var (
+	fenceLine     = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	headingLine   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleLine      = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	quoteLine     = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	bulletLine    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedLine  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	taskLine      = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	tableLine     = regexp.MustCompile(`^\s*\|`)
+	tableDelimRow = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Writer renders Markdown written to it as styled terminal text. Text is
+// rendered a line at a time, so it can be fed a streamed answer; tables are
+// held back until their last row has arrived.
+type Writer struct {
+	out     io.Writer
+	partial []byte   // text after the last newline
+	table   []string // rows of the table being read
+	code    *codeBlock
+}
+
+// codeBlock is an open fenced code block
+type codeBlock struct {
+	fence string
+	hl    *highlighter
+}
+
+// New returns a Writer rendering to out
+func New(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Write renders every complete line in p and buffers the rest
+func (w *Writer) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := strings.IndexByte(string(w.partial), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.partial[:i])
+		w.partial = w.partial[i+1:]
+		if err := w.line(strings.TrimSuffix(line, "\r")); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush renders any buffered text, ending it with a newline, and resets the
+// Writer for the next answer
+func (w *Writer) Flush() error {
+	if len(w.partial) > 0 {
+		line := string(w.partial)
+		w.partial = nil
+		if err := w.line(line); err != nil {
+			return err
+		}
+	}
+	err := w.flushTable()
+	w.code = nil
+	return err
+}
+
+// line renders one complete line
+func (w *Writer) line(line string) error {
+	if w.code != nil {
+		if strings.HasPrefix(strings.TrimSpace(line), w.code.fence) && strings.Trim(strings.TrimSpace(line), w.code.fence[:1]) == "" {
+			w.code = nil
+			return nil
+		}
+		return w.print("  " + w.code.hl.line(line))
+	}
+
+	if tableLine.MatchString(line) {
+		w.table = append(w.table, line)
+		return nil
+	}
+	if err := w.flushTable(); err != nil {
+		return err
+	}
+
+	if m := fenceLine.FindStringSubmatch(line); m != nil {
+		w.code = &codeBlock{fence: m[1], hl: newHighlighter(m[2])}
+		if m[2] != "" {
+			return w.print(gray + "  " + m[2] + reset)
+		}
+		return nil
+	}
+	return w.print(block(line))
+}
+
+// block renders a line outside of code blocks and tables
+func block(line string) string {
+	if m := headingLine.FindStringSubmatch(line); m != nil {
+		style := bold + cyan
+		if len(m[1]) == 1 {
+			style = bold + underline + cyan
+		}
+		return style + inline(m[2], style) + reset
+	}
+	if ruleLine.MatchString(line) {
+		return gray + strings.Repeat("─", 40) + reset
+	}
+	if m := quoteLine.FindStringSubmatch(line); m != nil {
+		return gray + "│ " + reset + italic + inline(m[1], italic) + reset
+	}
+	if m := bulletLine.FindStringSubmatch(line); m != nil {
+		text := m[2]
+		marker := "•"
+		if t := taskLine.FindStringSubmatch(text); t != nil {
+			marker, text = "☐", t[2]
+			if t[1] != " " {
+				marker = "☑"
+			}
+		}
+		return m[1] + yellow + marker + reset + " " + inline(text, "")
+	}
+	if m := numberedLine.FindStringSubmatch(line); m != nil {
+		return m[1] + yellow + m[2] + reset + " " + inline(m[3], "")
+	}
+	return inline(line, "")
+}
+
+func (w *Writer) print(s string) error {
+	_, err := io.WriteString(w.out, s+"\n")
+	return err
+}
@@ -0,0 +1,166 @@
+package render
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// flushTable renders the buffered table rows
+func (w *Writer) flushTable() error {
+	rows := w.table
+	w.table = nil
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Without a delimiter row under the header it is not a table
+	if len(rows) < 2 || !tableDelimRow.MatchString(rows[1]) {
+		for _, row := range rows {
+			if err := w.print(inline(row, "")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	header := splitRow(rows[0])
+	aligns := alignments(splitRow(rows[1]))
+	var body [][]string
+	for _, row := range rows[2:] {
+		body = append(body, splitRow(row))
+	}
+
+	// Render the cells first so the widths exclude the Markdown markup
+	columns := len(header)
+	for _, row := range body {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	render := func(cells []string, style string) []string {
+		out := make([]string, columns)
+		for i := range out {
+			if i < len(cells) {
+				out[i] = style + inline(cells[i], style) + reset
+			}
+		}
+		return out
+	}
+	cells := [][]string{render(header, bold)}
+	for _, row := range body {
+		cells = append(cells, render(row, ""))
+	}
+	widths := make([]int, columns)
+	for _, row := range cells {
+		for i, cell := range row {
+			if n := visibleWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	line := func(left, mid, right string) string {
+		parts := make([]string, columns)
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width+2)
+		}
+		return gray + left + strings.Join(parts, mid) + right + reset
+	}
+	if err := w.print(line("┌", "┬", "┐")); err != nil {
+		return err
+	}
+	for r, row := range cells {
+		parts := make([]string, columns)
+		for i, cell := range row {
+			align := byte('l')
+			if i < len(aligns) {
+				align = aligns[i]
+			}
+			parts[i] = " " + pad(cell, widths[i], align) + " "
+		}
+		sep := gray + "│" + reset
+		if err := w.print(sep + strings.Join(parts, sep) + sep); err != nil {
+			return err
+		}
+		if r == 0 {
+			if err := w.print(line("├", "┼", "┤")); err != nil {
+				return err
+			}
+		}
+	}
+	return w.print(line("└", "┴", "┘"))
+}
+
+// splitRow splits a table row into its cells
+func splitRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// alignments reads the column alignments from the delimiter row
+func alignments(delims []string) []byte {
+	aligns := make([]byte, len(delims))
+	for i, d := range delims {
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns[i] = 'c'
+		case strings.HasSuffix(d, ":"):
+			aligns[i] = 'r'
+		default:
+			aligns[i] = 'l'
+		}
+	}
+	return aligns
+}
+
+// pad aligns s within width columns
+func pad(s string, width int, align byte) string {
+	space := width - visibleWidth(s)
+	if space <= 0 {
+		return s
+	}
+	switch align {
+	case 'r':
+		return strings.Repeat(" ", space) + s
+	case 'c':
+		return strings.Repeat(" ", space/2) + s + strings.Repeat(" ", space-space/2)
+	}
+	return s + strings.Repeat(" ", space)
+}
+
+// visibleWidth counts the runes of s, skipping ANSI escape sequences
+func visibleWidth(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
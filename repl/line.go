@@ -0,0 +1,229 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt is returned when Ctrl-C is pressed while editing a line
+var errInterrupt = errors.New("interrupted")
+
+// lineReader reads lines from the terminal with basic editing and history.
+// When the input is not a terminal it reads plain lines instead.
+type lineReader struct {
+	in       *os.File
+	out      io.Writer
+	reader   *bufio.Reader
+	terminal bool
+	history  []string
+}
+
+func newLineReader(in *os.File, out io.Writer, terminal bool) *lineReader {
+	return &lineReader{
+		in:       in,
+		out:      out,
+		reader:   bufio.NewReader(in),
+		terminal: terminal,
+	}
+}
+
+// addHistory records a line for recall with the arrow keys
+func (l *lineReader) addHistory(line string) {
+	if line == "" || (len(l.history) > 0 && l.history[len(l.history)-1] == line) {
+		return
+	}
+	l.history = append(l.history, line)
+}
+
+// EditLine shows prompt followed by initial, which can be edited, and
+// returns the edited line. When in is not a terminal it reads a plain line
+// instead, keeping initial if the line is empty.
+func EditLine(in *os.File, out io.Writer, terminal bool, prompt, initial string) (string, error) {
+	l := newLineReader(in, out, terminal)
+	if terminal {
+		state, err := makeRaw(in.Fd())
+		if err == nil {
+			defer restore(in.Fd(), state)
+			return l.edit(prompt, initial)
+		}
+	}
+
+	line, err := l.readLine(prompt)
+	if err == nil && strings.TrimSpace(line) == "" {
+		line = initial
+	}
+	return line, err
+}
+
+// readLine shows prompt and reads a single line
+func (l *lineReader) readLine(prompt string) (string, error) {
+	if l.terminal {
+		state, err := makeRaw(l.in.Fd())
+		if err == nil {
+			defer restore(l.in.Fd(), state)
+			return l.edit(prompt, "")
+		}
+	}
+
+	fmt.Fprint(l.out, prompt)
+	line, err := l.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// edit implements the line editor while the terminal is in raw mode. The
+// line starts out as initial.
+func (l *lineReader) edit(prompt, initial string) (string, error) {
+	buf := []rune(initial)
+	pos := len(buf)
+	historyIndex := len(l.history)
+	pending := ""
+
+	refresh := func() {
+		fmt.Fprintf(l.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(l.out, "\x1b[%dD", n)
+		}
+	}
+	recall := func(index int) {
+		if historyIndex == len(l.history) {
+			pending = string(buf)
+		}
+		historyIndex = index
+		if index == len(l.history) {
+			buf = []rune(pending)
+		} else {
+			buf = []rune(l.history[index])
+		}
+		pos = len(buf)
+	}
+
+	fmt.Fprint(l.out, prompt+initial)
+	for {
+		r, _, err := l.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(l.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(l.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(l.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = buf[pos:]
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(l.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			if historyIndex > 0 {
+				recall(historyIndex - 1)
+			}
+		case 14: // Ctrl-N
+			if historyIndex < len(l.history) {
+				recall(historyIndex + 1)
+			}
+		case 27: // Escape sequence
+			switch l.readEscape() {
+			case "A": // Up
+				if historyIndex > 0 {
+					recall(historyIndex - 1)
+				}
+			case "B": // Down
+				if historyIndex < len(l.history) {
+					recall(historyIndex + 1)
+				}
+			case "C": // Right
+				if pos < len(buf) {
+					pos++
+				}
+			case "D": // Left
+				if pos > 0 {
+					pos--
+				}
+			case "H", "1~", "7~": // Home
+				pos = 0
+			case "F", "4~", "8~": // End
+				pos = len(buf)
+			case "3~": // Delete
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r == '\t' || r >= 32 {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+
+		refresh()
+	}
+}
+
+// readEscape reads the rest of a CSI or SS3 escape sequence and returns its
+// final part, e.g. "A" for the up arrow or "3~" for delete
+func (l *lineReader) readEscape() string {
+	next, _, err := l.reader.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return ""
+	}
+
+	var seq strings.Builder
+	for {
+		r, _, err := l.reader.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		if r >= 0x40 && r <= 0x7e {
+			return seq.String()
+		}
+	}
+}
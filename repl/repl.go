@@ -0,0 +1,192 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// multiLineDelimiter starts and ends a block of multi-line input
+const multiLineDelimiter = `"""`
+
+// Command is a slash command available in the REPL
+type Command struct {
+	Name  string
+	Usage string
+	Help  string
+	Run   func(args string) error
+}
+
+// Options configures an interactive session
+type Options struct {
+	// Prompt returns the prompt shown before each input, so it can reflect
+	// the current context
+	Prompt func() string
+
+	// Ask sends a prompt to the model. The context is cancelled when Ctrl-C
+	// is pressed while the request is in flight.
+	Ask func(ctx context.Context, prompt string) error
+
+	Commands []Command
+
+	In       *os.File
+	Out      io.Writer
+	Terminal bool
+}
+
+// Run reads prompts until the user exits with /exit or Ctrl-D
+func Run(opts Options) error {
+	lines := newLineReader(opts.In, opts.Out, opts.Terminal)
+
+	commands := make(map[string]Command)
+	for _, cmd := range opts.Commands {
+		commands[cmd.Name] = cmd
+	}
+
+	// Ctrl-C cancels the request in flight rather than quitting. While a
+	// line is being edited the terminal is in raw mode and Ctrl-C is read as
+	// a key instead.
+	var mu sync.Mutex
+	var cancel context.CancelFunc
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
+	go func() {
+		for range sigs {
+			mu.Lock()
+			if cancel != nil {
+				cancel()
+			}
+			mu.Unlock()
+		}
+	}()
+
+	fmt.Fprintln(opts.Out, "💬 Interactive chat. Type /help for commands, /exit or Ctrl-D to quit.")
+	fmt.Fprintf(opts.Out, "   End a line with \\ or wrap text in %s to enter multiple lines.\n", multiLineDelimiter)
+
+	for {
+		input, err := readInput(lines, opts.Prompt())
+		if err == errInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if !strings.Contains(input, "\n") {
+			lines.addHistory(input)
+		}
+
+		if strings.HasPrefix(input, "/") {
+			name, args := input[1:], ""
+			if i := strings.IndexAny(name, " \t"); i >= 0 {
+				name, args = name[:i], strings.TrimSpace(name[i+1:])
+			}
+
+			switch name {
+			case "exit", "quit":
+				return nil
+			case "help":
+				printHelp(opts.Out, commands)
+				continue
+			}
+
+			cmd, ok := commands[name]
+			if !ok {
+				fmt.Fprintf(opts.Out, "❌ Unknown command: /%s (type /help for commands)\n", name)
+				continue
+			}
+			if err := cmd.Run(args); err != nil {
+				fmt.Fprintf(opts.Out, "❌ %v\n", err)
+			}
+			continue
+		}
+
+		ctx, cancelRequest := context.WithCancel(context.Background())
+		mu.Lock()
+		cancel = cancelRequest
+		mu.Unlock()
+
+		err = opts.Ask(ctx, input)
+
+		mu.Lock()
+		cancel = nil
+		mu.Unlock()
+		cancelRequest()
+
+		if errors.Is(err, context.Canceled) || (err != nil && ctx.Err() != nil) {
+			fmt.Fprintln(opts.Out)
+			fmt.Fprintln(opts.Out, "⏹️  Request cancelled.")
+		} else if err != nil {
+			fmt.Fprintf(opts.Out, "❌ %v\n", err)
+		}
+	}
+}
+
+// readInput reads one prompt, joining continuation lines ending in a
+// backslash and blocks wrapped in triple quotes
+func readInput(lines *lineReader, prompt string) (string, error) {
+	line, err := lines.readLine(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(line) == multiLineDelimiter {
+		var block []string
+		for {
+			next, err := lines.readLine("... ")
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(next) == multiLineDelimiter {
+				return strings.Join(block, "\n"), nil
+			}
+			block = append(block, next)
+		}
+	}
+
+	for strings.HasSuffix(line, "\\") {
+		next, err := lines.readLine("... ")
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSuffix(line, "\\") + "\n" + next
+	}
+	return line, nil
+}
+
+func printHelp(out io.Writer, commands map[string]Command) {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(out, "Commands:")
+	for _, name := range names {
+		cmd := commands[name]
+		usage := cmd.Usage
+		if usage == "" {
+			usage = "/" + cmd.Name
+		}
+		fmt.Fprintf(out, "  %-20s %s\n", usage, cmd.Help)
+	}
+	fmt.Fprintf(out, "  %-20s %s\n", "/help", "Show this help")
+	fmt.Fprintf(out, "  %-20s %s\n", "/exit", "Leave the chat")
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package repl
+
+import "errors"
+
+type termState struct{}
+
+// makeRaw is not supported on this platform, so input falls back to plain
+// line reading without editing
+func makeRaw(fd uintptr) (*termState, error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
+
+func restore(fd uintptr, state *termState) error {
+	return nil
+}
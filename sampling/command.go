@@ -0,0 +1,86 @@
+package sampling
+
+import (
+	"flag"
+	"fmt"
+
+	"ask/config"
+)
+
+// IsCommand reports whether arg is an ask sampling action
+func IsCommand(arg string) bool {
+	return arg == "show" || arg == "set" || arg == "clear"
+}
+
+// RunCommand runs ask sampling show|set|clear [--global] [options]. set
+// changes only the options given and keeps the others.
+func RunCommand(args []string) error {
+	action := args[0]
+	var given config.Sampling
+	fs := flag.NewFlagSet("sampling "+action, flag.ContinueOnError)
+	global := fs.Bool("global", false, "Apply to the global options instead of the current context")
+	if action == "set" {
+		AddFlags(fs, &given)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	current := cfg.GetCurrentContext()
+
+	if action == "show" {
+		fmt.Printf("Global sampling options: %s\n", describe(cfg.Sampling, "(none)"))
+		if current != nil {
+			fmt.Printf("Context sampling options (%s): %s\n", current.Name, describe(current.Sampling, "(none, uses the global options)"))
+		}
+		return nil
+	}
+
+	var sampling *config.Sampling
+	if action == "set" {
+		if given.IsZero() {
+			return fmt.Errorf("usage: ask sampling set [--global] --temperature 0.2 [--max-tokens 500 ...]")
+		}
+		existing := cfg.Sampling
+		if !*global && current != nil {
+			existing = current.Sampling
+		}
+		merged := config.Sampling{}.Merge(existing).Merge(&given)
+		sampling = &merged
+	}
+
+	scope := "global"
+	if *global {
+		cfg.Sampling = sampling
+	} else {
+		if err := cfg.SetCurrentContextSampling(sampling); err != nil {
+			return err
+		}
+		scope = "context"
+	}
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
+	}
+
+	if sampling == nil {
+		fmt.Printf("🗑️  Cleared the %s sampling options.\n", scope)
+	} else {
+		fmt.Printf("✅ Set the %s sampling options: %s\n", scope, Describe(*sampling))
+	}
+	return nil
+}
+
+// describe describes stored options, or returns none if they are unset
+func describe(s *config.Sampling, none string) string {
+	if s == nil {
+		return none
+	}
+	return Describe(*s)
+}
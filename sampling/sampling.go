@@ -0,0 +1,140 @@
+package sampling
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ask/config"
+)
+
+// anthropicMaxTemperature is the highest temperature the Anthropic API
+// accepts; the others accept up to 2
+const anthropicMaxTemperature = 1.0
+
+// ReasoningEfforts are the accepted values of reasoning_effort
+var ReasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// AddFlags defines the sampling flags on fs. The options given are set in s.
+func AddFlags(fs *flag.FlagSet, s *config.Sampling) {
+	fs.Func("temperature", "Sampling temperature, from 0 to 2", floatFlag(&s.Temperature, 0, 2))
+	fs.Func("top-p", "Nucleus sampling: only consider the most likely tokens up to this probability, from 0 to 1", floatFlag(&s.TopP, 0, 1))
+	fs.Func("max-tokens", "Longest answer in tokens", func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("must be a positive number")
+		}
+		s.MaxTokens = n
+		return nil
+	})
+	fs.Func("presence-penalty", "Penalize tokens that already appeared, from -2 to 2", floatFlag(&s.PresencePenalty, -2, 2))
+	fs.Func("frequency-penalty", "Penalize tokens by how often they appeared, from -2 to 2", floatFlag(&s.FrequencyPenalty, -2, 2))
+	fs.Func("seed", "Sample deterministically where the model supports it", func(value string) error {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		s.Seed = &seed
+		return nil
+	})
+	fs.Func("stop", "End the answer at this text (repeatable)", func(value string) error {
+		if value == "" {
+			return fmt.Errorf("cannot be empty")
+		}
+		s.Stop = append(s.Stop, value)
+		return nil
+	})
+	fs.Func("reasoning-effort", "How much reasoning models think: "+strings.Join(ReasoningEfforts, ", "), func(value string) error {
+		for _, effort := range ReasoningEfforts {
+			if value == effort {
+				s.ReasoningEffort = value
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(ReasoningEfforts, ", "))
+	})
+}
+
+func floatFlag(target **float64, min, max float64) func(string) error {
+	return func(value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < min || f > max {
+			return fmt.Errorf("must be between %g and %g", min, max)
+		}
+		*target = &f
+		return nil
+	}
+}
+
+// Adapt leaves out the options of s that the model or provider does not
+// accept, and lowers values above what they accept to the limit. It
+// returns the adapted options and what was changed, e.g. "temperature".
+func Adapt(s config.Sampling, providerName string, info config.ModelInfo) (config.Sampling, []string) {
+	var changed []string
+	drop := func(name string, set bool, clear func()) {
+		if set {
+			clear()
+			changed = append(changed, name)
+		}
+	}
+
+	if info.NoSampling {
+		drop("temperature", s.Temperature != nil, func() { s.Temperature = nil })
+		drop("top_p", s.TopP != nil, func() { s.TopP = nil })
+		drop("presence_penalty", s.PresencePenalty != nil, func() { s.PresencePenalty = nil })
+		drop("frequency_penalty", s.FrequencyPenalty != nil, func() { s.FrequencyPenalty = nil })
+		drop("stop", len(s.Stop) > 0, func() { s.Stop = nil })
+	}
+	// Only the OpenAI API takes a reasoning effort
+	if !info.Reasoning || providerName != config.ProviderOpenAI {
+		drop("reasoning_effort", s.ReasoningEffort != "", func() { s.ReasoningEffort = "" })
+	}
+	// The Anthropic API has no penalties or seed, and its temperature only
+	// goes up to 1
+	if providerName == config.ProviderAnthropic {
+		if s.Temperature != nil && *s.Temperature > anthropicMaxTemperature {
+			limit := anthropicMaxTemperature
+			s.Temperature = &limit
+			changed = append(changed, fmt.Sprintf("temperature above %g", limit))
+		}
+		drop("presence_penalty", s.PresencePenalty != nil, func() { s.PresencePenalty = nil })
+		drop("frequency_penalty", s.FrequencyPenalty != nil, func() { s.FrequencyPenalty = nil })
+		drop("seed", s.Seed != nil, func() { s.Seed = nil })
+	}
+	if info.MaxOutput > 0 && s.MaxTokens > info.MaxOutput {
+		s.MaxTokens = info.MaxOutput
+		changed = append(changed, fmt.Sprintf("max_tokens above %d", info.MaxOutput))
+	}
+	return s, changed
+}
+
+// Describe lists the options set in s, e.g. "temperature 0.2, seed 7"
+func Describe(s config.Sampling) string {
+	var parts []string
+	addFloat := func(name string, value *float64) {
+		if value != nil {
+			parts = append(parts, name+" "+strconv.FormatFloat(*value, 'g', -1, 64))
+		}
+	}
+	addFloat("temperature", s.Temperature)
+	addFloat("top_p", s.TopP)
+	if s.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max_tokens %d", s.MaxTokens))
+	}
+	addFloat("presence_penalty", s.PresencePenalty)
+	addFloat("frequency_penalty", s.FrequencyPenalty)
+	if s.Seed != nil {
+		parts = append(parts, fmt.Sprintf("seed %d", *s.Seed))
+	}
+	for _, stop := range s.Stop {
+		parts = append(parts, fmt.Sprintf("stop %q", stop))
+	}
+	if s.ReasoningEffort != "" {
+		parts = append(parts, "reasoning_effort "+s.ReasoningEffort)
+	}
+	if len(parts) == 0 {
+		return "model defaults"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,86 @@
+package sampling
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"ask/config"
+)
+
+func float(f float64) *float64 { return &f }
+
+func TestAdapt(t *testing.T) {
+	seed := int64(7)
+	all := config.Sampling{
+		Temperature:      float(1.5),
+		TopP:             float(0.9),
+		MaxTokens:        200000,
+		PresencePenalty:  float(0.5),
+		FrequencyPenalty: float(0.5),
+		Seed:             &seed,
+		Stop:             []string{"END"},
+		ReasoningEffort:  "low",
+	}
+	tests := []struct {
+		provider, model string
+		changed         string
+	}{
+		{config.ProviderOpenAI, "gpt-4o", "reasoning_effort, max_tokens above 16384"},
+		{config.ProviderOpenAI, "o3", "temperature, top_p, presence_penalty, frequency_penalty, stop, max_tokens above 100000"},
+		{config.ProviderOpenAI, "gpt-5-chat-latest", "reasoning_effort, max_tokens above 16384"},
+		{config.ProviderOpenAI, "some-local-model", ""},
+		{config.ProviderAnthropic, "claude-sonnet-4-5", "reasoning_effort, temperature above 1, presence_penalty, frequency_penalty, seed, max_tokens above 64000"},
+		{config.ProviderOllama, "llama3.2", "reasoning_effort"},
+	}
+	for _, test := range tests {
+		adapted, changed := Adapt(all, test.provider, config.LookupModel(test.model))
+		if got := strings.Join(changed, ", "); got != test.changed {
+			t.Errorf("%s %s: changed %q, want %q", test.provider, test.model, got, test.changed)
+		}
+		// What is left is accepted as it is
+		if _, again := Adapt(adapted, test.provider, config.LookupModel(test.model)); len(again) > 0 {
+			t.Errorf("%s %s: adapted options still change: %v", test.provider, test.model, again)
+		}
+	}
+
+	adapted, _ := Adapt(config.Sampling{Temperature: float(1.5)}, config.ProviderAnthropic, config.LookupModel("claude-haiku-4-5"))
+	if adapted.Temperature == nil || *adapted.Temperature != 1 {
+		t.Errorf("Anthropic temperature = %v, want it lowered to 1", adapted.Temperature)
+	}
+	adapted, changed := Adapt(config.Sampling{Temperature: float(0.7)}, config.ProviderAnthropic, config.LookupModel("claude-haiku-4-5"))
+	if len(changed) > 0 || *adapted.Temperature != 0.7 {
+		t.Errorf("Anthropic temperature 0.7 changed: %v", changed)
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	var s config.Sampling
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	AddFlags(fs, &s)
+	err := fs.Parse([]string{"--temperature", "0.2", "--max-tokens", "50", "--seed", "3", "--stop", "A", "--stop", "B", "--reasoning-effort", "high"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Describe(s); got != `temperature 0.2, max_tokens 50, seed 3, stop "A", stop "B", reasoning_effort high` {
+		t.Errorf("Describe = %s", got)
+	}
+
+	for _, args := range [][]string{
+		{"--temperature", "2.5"},
+		{"--top-p", "-0.1"},
+		{"--max-tokens", "0"},
+		{"--presence-penalty", "3"},
+		{"--seed", "x"},
+		{"--stop", ""},
+		{"--reasoning-effort", "huge"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(new(strings.Builder))
+		AddFlags(fs, &config.Sampling{})
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
@@ -2,17 +2,19 @@ package setup
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 
 	"ask/config"
+	"ask/models"
 )
 
 // Run starts the interactive setup process
 func Run() error {
 	fmt.Println("🤖 Welcome to Ask CLI Setup!")
-	fmt.Println("This will help you configure your OpenAI API key and preferred model.")
+	fmt.Println("This will help you configure your provider, API key and preferred model.")
 	fmt.Println()
 
 	cfg, err := config.Load()
@@ -20,45 +22,86 @@ func Run() error {
 		return fmt.Errorf("failed to load config: %v", err)
 	}
 
-	// Get API Key
-	fmt.Println("📝 Step 1: OpenAI API Key")
-	fmt.Println("You can get your API key from: https://platform.openai.com/account/api-keys")
-	fmt.Println()
-
 	reader := bufio.NewReader(os.Stdin)
 
-	// Check if API key already exists
-	if cfg.APIKey != "" {
-		fmt.Print("API key already configured. Do you want to update it? (y/N): ")
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
+	// Get Provider
+	fmt.Println("🌐 Step 1: Choose your provider")
+	providers := config.GetAvailableProviders()
+	for i, provider := range providers {
+		fmt.Printf("  %d. %s\n", i+1, provider)
+	}
+	fmt.Printf("Enter the number of your provider (current: %s, press Enter to keep): ", cfg.GetProvider())
+	providerChoice, _ := reader.ReadString('\n')
+	providerChoice = strings.TrimSpace(providerChoice)
 
-		if response != "y" && response != "yes" {
-			fmt.Println("Keeping existing API key.")
-		} else {
-			cfg.APIKey = ""
+	if providerChoice != "" {
+		var choice int
+		fmt.Sscanf(providerChoice, "%d", &choice)
+
+		if choice < 1 || choice > len(providers) {
+			return fmt.Errorf("invalid choice. Please select a number between 1 and %d", len(providers))
+		}
+
+		if providers[choice-1] != cfg.GetProvider() {
+			cfg.Provider = providers[choice-1]
+			cfg.Model = ""
 		}
 	}
+	provider := cfg.GetProvider()
+
+	// Local servers don't need an API key but usually need a base URL
+	if !config.RequiresAPIKey(provider, "") {
+		fmt.Println()
+		fmt.Println("🔗 Step 2: Server URL")
+		fmt.Printf("Enter the server base URL (press Enter for %s): ", defaultBaseURL(provider, cfg))
+		baseURL, _ := reader.ReadString('\n')
+		baseURL = strings.TrimSpace(baseURL)
+		if baseURL != "" {
+			cfg.SetBaseURL(provider, baseURL)
+		}
+	} else {
+		// Get API Key
+		fmt.Println()
+		fmt.Println("📝 Step 2: API Key")
+		fmt.Printf("You can get your API key from: %s\n", apiKeyURL(provider))
+		fmt.Println()
+
+		// Check if API key already exists
+		if cfg.GetAPIKey(provider) != "" {
+			fmt.Print("API key already configured. Do you want to update it? (y/N): ")
+			response, _ := reader.ReadString('\n')
+			response = strings.TrimSpace(strings.ToLower(response))
+
+			if response != "y" && response != "yes" {
+				fmt.Println("Keeping existing API key.")
+			} else {
+				cfg.SetAPIKey(provider, "")
+			}
+		}
 
-	if cfg.APIKey == "" {
-		fmt.Print("Enter your OpenAI API key: ")
-		apiKey, _ := reader.ReadString('\n')
-		apiKey = strings.TrimSpace(apiKey)
+		if cfg.GetAPIKey(provider) == "" {
+			fmt.Printf("Enter your %s API key: ", provider)
+			apiKey, _ := reader.ReadString('\n')
+			apiKey = strings.TrimSpace(apiKey)
 
-		if apiKey == "" {
-			return fmt.Errorf("API key cannot be empty")
-		}
+			if apiKey == "" {
+				return fmt.Errorf("API key cannot be empty")
+			}
 
-		cfg.APIKey = apiKey
+			cfg.SetAPIKey(provider, apiKey)
+		}
 	}
 
 	// Get Model Selection
 	fmt.Println()
-	fmt.Println("🤖 Step 2: Choose your preferred model")
+	fmt.Println("🤖 Step 3: Choose your preferred model")
 	fmt.Println("Available models:")
 
-	models := config.GetAvailableModels()
-	for i, model := range models {
+	available := models.Load(context.Background(), cfg, provider, cfg.GetBaseURL(provider), models.Options{Refresh: true})
+	if available.Err != nil {
+		fmt.Printf("⚠️  Could not fetch the list of models (%v), showing the %s\n", available.Err, available.Describe())
+	}
+	for i, model := range available.Models {
 		fmt.Printf("  %d. %s\n", i+1, model)
 	}
 	fmt.Println()
@@ -78,18 +121,13 @@ func Run() error {
 	}
 
 	if cfg.Model == "" {
-		fmt.Print("Enter the number of your preferred model (1-6): ")
+		fmt.Printf("Enter the number (1-%d) or name of your preferred model: ", len(available.Models))
 		modelChoice, _ := reader.ReadString('\n')
-		modelChoice = strings.TrimSpace(modelChoice)
-
-		var choice int
-		fmt.Sscanf(modelChoice, "%d", &choice)
-
-		if choice < 1 || choice > len(models) {
-			return fmt.Errorf("invalid choice. Please select a number between 1 and %d", len(models))
+		model, err := available.Choose(strings.TrimSpace(modelChoice))
+		if err != nil {
+			return err
 		}
-
-		cfg.Model = models[choice-1]
+		cfg.Model = model
 	}
 
 	// Save configuration
@@ -109,6 +147,24 @@ func Run() error {
 	return nil
 }
 
+// apiKeyURL returns the page where users can create an API key for a provider
+func apiKeyURL(provider string) string {
+	switch provider {
+	case config.ProviderAnthropic:
+		return "https://console.anthropic.com/settings/keys"
+	default:
+		return "https://platform.openai.com/account/api-keys"
+	}
+}
+
+// defaultBaseURL returns the base URL shown as the default for a local provider
+func defaultBaseURL(provider string, cfg *config.Config) string {
+	if baseURL := cfg.GetBaseURL(provider); baseURL != "" {
+		return baseURL
+	}
+	return "http://localhost:11434"
+}
+
 // GetConfigPath returns the path to the config file
 func GetConfigPath() string {
 	return config.GetConfigPath()
@@ -0,0 +1,164 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"ask/code"
+)
+
+// maxRecordedOutput is how much of a command's output is kept for the
+// conversation history. Longer output keeps its end.
+const maxRecordedOutput = 8 << 10
+
+// Env is the operating system and shell commands are suggested for
+type Env struct {
+	OS    string // human-readable, e.g. "Ubuntu 24.04 LTS (Linux)"
+	Shell string // shell name, e.g. "zsh"
+	Path  string // shell executable
+}
+
+// Detect returns the current operating system and the user's shell
+func Detect() Env {
+	env := Env{OS: osName()}
+
+	env.Path = os.Getenv("SHELL")
+	if env.Path == "" && runtime.GOOS == "windows" {
+		for _, name := range []string{"pwsh", "powershell"} {
+			if path, err := exec.LookPath(name); err == nil {
+				env.Path = path
+				break
+			}
+		}
+		if env.Path == "" {
+			env.Path = os.Getenv("ComSpec")
+		}
+	}
+	if env.Path == "" {
+		env.Path = "/bin/sh"
+	}
+	env.Shell = strings.TrimSuffix(strings.ToLower(filepath.Base(env.Path)), ".exe")
+	return env
+}
+
+// osName describes the operating system, including the Linux distribution
+func osName() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "macOS"
+	case "windows":
+		return "Windows"
+	case "linux":
+		if distro := linuxDistro(); distro != "" {
+			return distro + " (Linux)"
+		}
+		return "Linux"
+	}
+	return runtime.GOOS
+}
+
+func linuxDistro() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// Instructions returns the system prompt asking the model for a single
+// command
+func Instructions(env Env) string {
+	return fmt.Sprintf(`You turn requests into shell commands for %s on %s.
+Reply with exactly one command in a single fenced code block, followed by a short explanation of what it does in one or two sentences.
+The command may combine several programs with pipes or && but must run as a single line.
+Only use tools that are commonly installed on this system. Prefer safe options and warn in the explanation if the command deletes or overwrites data.`, env.Shell, env.OS)
+}
+
+// Parse splits the model's answer into the command and its explanation
+func Parse(answer string) (command, explanation string) {
+	blocks := code.Extract(answer)
+	if len(blocks) == 0 {
+		// Without a code block, a one-line answer is the command itself
+		answer = strings.TrimSpace(answer)
+		if !strings.Contains(answer, "\n") {
+			return strings.Trim(answer, "`"), ""
+		}
+		return "", answer
+	}
+
+	command = strings.TrimSpace(blocks[0].Code)
+	explanation = answer
+	if start := strings.Index(answer, "```"); start >= 0 {
+		rest := answer[start+3:]
+		if end := strings.Index(rest, "```"); end >= 0 {
+			explanation = answer[:start] + rest[end+3:]
+		}
+	}
+	return command, strings.TrimSpace(explanation)
+}
+
+// Run executes command with the user's shell, connected to the terminal,
+// and returns its exit status and the end of its output
+func Run(env Env, command string) (int, string, error) {
+	var args []string
+	switch env.Shell {
+	case "cmd":
+		args = []string{"/C", command}
+	case "powershell", "pwsh":
+		args = []string{"-NoProfile", "-Command", command}
+	default:
+		args = []string{"-c", command}
+	}
+
+	var captured tail
+	cmd := exec.Command(env.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, &captured)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &captured)
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), captured.String(), nil
+	}
+	if err != nil {
+		return -1, captured.String(), fmt.Errorf("failed to run %s: %v", env.Shell, err)
+	}
+	return 0, captured.String(), nil
+}
+
+// tail keeps the last maxRecordedOutput bytes written to it
+type tail struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	if extra := t.buf.Len() - maxRecordedOutput; extra > 0 {
+		t.buf.Next(extra)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	if t.truncated {
+		return "[... output truncated ...]\n" + t.buf.String()
+	}
+	return t.buf.String()
+}
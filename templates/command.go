@@ -0,0 +1,68 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsCommand reports whether arg is an action of the template subcommand
+func IsCommand(arg string) bool {
+	return arg == "list" || arg == "show"
+}
+
+// RunCommand runs ask template list|show [name]
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ask template list|show [name]")
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := List()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("📝 No templates found.")
+			fmt.Printf("Create one as %s/<name>%s, e.g. \"Explain this error in {{lang}}: {{input}}\"\n", Dir(), Extension)
+			return nil
+		}
+		fmt.Println("📝 Available templates:")
+		for _, name := range names {
+			vars := ""
+			if source, err := Load(name); err == nil {
+				if t, err := Parse(name, source, 0); err == nil {
+					vars = strings.Join(t.Variables(), ", ")
+				}
+			}
+			if vars != "" {
+				fmt.Printf("  %s (%s)\n", name, vars)
+			} else {
+				fmt.Printf("  %s\n", name)
+			}
+		}
+		return nil
+	case "show":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: ask template show <name>")
+		}
+		source, err := Load(args[1])
+		if err != nil {
+			return err
+		}
+		t, err := Parse(args[1], source, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimRight(source, "\n"))
+		fmt.Println()
+		if len(t.required) > 0 {
+			fmt.Printf("Required variables: %s\n", strings.Join(t.required, ", "))
+		}
+		if len(t.optional) > 0 {
+			fmt.Printf("Optional variables: %s\n", strings.Join(t.optional, ", "))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown template command: %s", args[0])
+}
@@ -0,0 +1,274 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"ask/config"
+)
+
+// Extension is the file extension of stored templates
+const Extension = ".tmpl"
+
+// Dir returns the directory holding the prompt templates
+func Dir() string {
+	return filepath.Join(config.GetConfigDir(), "templates")
+}
+
+// path returns the file for a template name
+func path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid template name: %q", name)
+	}
+	return filepath.Join(Dir(), name+Extension), nil
+}
+
+// Load reads the source of a template by name
+func Load(name string) (string, error) {
+	file, err := path(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("template not found: %s (looked for %s)", name, file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %v", name, err)
+	}
+	return string(data), nil
+}
+
+// List returns the names of all stored templates
+func List() ([]string, error) {
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), Extension) {
+			names = append(names, strings.TrimSuffix(entry.Name(), Extension))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Template is a parsed prompt template
+type Template struct {
+	name     string
+	tmpl     *template.Template
+	required []string // variables used outside of any condition
+	optional []string // variables only used where a condition checks them
+}
+
+// bareVar matches {{name}}, the short form of {{.name}}
+var bareVar = regexp.MustCompile(`\{\{(-?\s*)([A-Za-z_][A-Za-z0-9_]*)(\s*-?)\}\}`)
+
+// keywords are the bare words text/template gives a meaning to
+var keywords = map[string]bool{
+	"end": true, "else": true, "break": true, "continue": true,
+	"nil": true, "true": true, "false": true,
+}
+
+// Parse parses a template source. Variables may be written as {{.name}} or
+// simply {{name}}.
+func Parse(name, source string, maxFileBytes int64) (*Template, error) {
+	funcs := helpers(maxFileBytes)
+	source = bareVar.ReplaceAllStringFunc(source, func(m string) string {
+		parts := bareVar.FindStringSubmatch(m)
+		if keywords[parts[2]] || funcs[parts[2]] != nil {
+			return m
+		}
+		return "{{" + parts[1] + "." + parts[2] + parts[3] + "}}"
+	})
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+
+	w := &walker{required: map[string]bool{}, optional: map[string]bool{}}
+	if tmpl.Tree != nil {
+		w.walk(tmpl.Tree.Root, nil)
+	}
+
+	t := &Template{name: name, tmpl: tmpl}
+	for v := range w.required {
+		t.required = append(t.required, v)
+	}
+	for v := range w.optional {
+		if !w.required[v] {
+			t.optional = append(t.optional, v)
+		}
+	}
+	sort.Strings(t.required)
+	sort.Strings(t.optional)
+	return t, nil
+}
+
+// Variables returns the names of the variables the template uses
+func (t *Template) Variables() []string {
+	vars := append(append([]string{}, t.required...), t.optional...)
+	sort.Strings(vars)
+	return vars
+}
+
+// Missing returns the required variables that vars does not set
+func (t *Template) Missing(vars map[string]string) []string {
+	var missing []string
+	for _, v := range t.required {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// Render executes the template. It fails without rendering anything if a
+// required variable is not set.
+func (t *Template) Render(vars map[string]string) (string, error) {
+	if missing := t.Missing(vars); len(missing) > 0 {
+		return "", fmt.Errorf("template %s is missing variable(s): %s (set them with --var key=value)", t.name, strings.Join(missing, ", "))
+	}
+
+	// Variables that are only tested in conditions may be left unset
+	data := make(map[string]string, len(vars)+len(t.optional))
+	for _, v := range t.optional {
+		data[v] = ""
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	var out strings.Builder
+	if err := t.tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %v", t.name, err)
+	}
+	return out.String(), nil
+}
+
+// helpers returns the functions available to templates
+func helpers(maxFileBytes int64) template.FuncMap {
+	return template.FuncMap{
+		// file returns the contents of a file
+		"file": func(path string) (string, error) {
+			info, err := os.Stat(path)
+			if err != nil {
+				return "", err
+			}
+			if info.Size() > maxFileBytes {
+				return "", fmt.Errorf("%s is larger than the maximum input size", path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+		// env returns an environment variable
+		"env": os.Getenv,
+		// date returns the current date, formatted with an optional Go
+		// time layout
+		"date": func(layout ...string) string {
+			if len(layout) > 0 {
+				return time.Now().Format(layout[0])
+			}
+			return time.Now().Format("2006-01-02")
+		},
+	}
+}
+
+// walker collects the variables referenced by a template
+type walker struct {
+	required map[string]bool
+	optional map[string]bool
+}
+
+// walk records the variables in node. Variables in guarded have been
+// tested by an enclosing condition, so they are optional.
+func (w *walker) walk(node parse.Node, guarded map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, guarded)
+		}
+	case *parse.ActionNode:
+		w.pipe(n.Pipe, guarded)
+	case *parse.IfNode:
+		// A variable tested by the condition may be unset
+		tested := map[string]bool{}
+		for v := range guarded {
+			tested[v] = true
+		}
+		for _, v := range fields(n.Pipe) {
+			tested[v] = true
+			w.optional[v] = true
+		}
+		w.walk(n.List, tested)
+		w.walk(n.ElseList, guarded)
+	case *parse.RangeNode:
+		// Inside range and with, dot is no longer the variables
+		w.pipe(n.Pipe, guarded)
+		w.walk(n.ElseList, guarded)
+	case *parse.WithNode:
+		for _, v := range fields(n.Pipe) {
+			w.optional[v] = true
+		}
+		w.walk(n.ElseList, guarded)
+	case *parse.TemplateNode:
+		w.pipe(n.Pipe, guarded)
+	}
+}
+
+func (w *walker) pipe(pipe *parse.PipeNode, guarded map[string]bool) {
+	for _, v := range fields(pipe) {
+		if guarded[v] {
+			w.optional[v] = true
+		} else {
+			w.required[v] = true
+		}
+	}
+}
+
+// fields returns the top-level fields referenced by a pipeline
+func fields(pipe *parse.PipeNode) []string {
+	if pipe == nil {
+		return nil
+	}
+	var names []string
+	var visit func(parse.Node)
+	visit = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			names = append(names, n.Ident[0])
+		case *parse.ChainNode:
+			visit(n.Node)
+		case *parse.PipeNode:
+			for _, cmd := range n.Cmds {
+				visit(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				visit(arg)
+			}
+		}
+	}
+	visit(pipe)
+	return names
+}
@@ -0,0 +1,38 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseVars parses key=value assignments. A value of @path is replaced by
+// the contents of the file at path.
+func ParseVars(assignments []string, maxFileBytes int64) (map[string]string, error) {
+	vars := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q: expected key=value", assignment)
+		}
+
+		if strings.HasPrefix(value, "@") {
+			path := value[1:]
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read variable %s: %v", key, err)
+			}
+			if info.Size() > maxFileBytes {
+				return nil, fmt.Errorf("variable %s: %s is larger than the maximum input size", key, path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read variable %s: %v", key, err)
+			}
+			value = string(data)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
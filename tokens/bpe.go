@@ -0,0 +1,306 @@
+package tokens
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"ask/config"
+)
+
+// OpenAI models are counted with their own byte-pair encoding. The rank
+// tables are the ones tiktoken uses; they are too large to ship with ask,
+// so they are downloaded once into the tokenizers directory.
+
+const (
+	// downloadTimeout limits fetching a rank table
+	downloadTimeout = 15 * time.Second
+	// retryDownloadAfter is how long a failed download is not tried again
+	retryDownloadAfter = 24 * time.Hour
+)
+
+// downloadURL is where the rank tables are fetched from. Empty disables
+// downloading.
+var downloadURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// tokenizersDir returns the directory holding the rank tables
+var tokenizersDir = func() string {
+	return filepath.Join(config.GetConfigDir(), "tokenizers")
+}
+
+// Whitespace as the tokenizers define it: Unicode White_Space, which Go's
+// \s does not cover
+const space = `\t\n\v\f\r\x{85}\p{Z}`
+
+// The pre-tokenizer patterns of cl100k_base and o200k_base. Go's regexp
+// has no lookahead, so the \s+(?!\S) alternative is applied by split.
+var (
+	cl100kPattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^` + space + `\p{L}\p{N}]+[\r\n]*|[` + space + `]*[\r\n]+|[` + space + `]+)`)
+	o200kPattern  = regexp.MustCompile(`^(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^` + space + `\p{L}\p{N}]+[\r\n/]*|[` + space + `]*[\r\n]+|[` + space + `]+)`)
+)
+
+// encoding is a byte-pair encoding whose rank table is loaded on first use
+type encoding struct {
+	name    string
+	pattern *regexp.Regexp
+
+	once  sync.Once
+	ranks map[string]int // nil when the table is unavailable
+}
+
+var (
+	cl100k = &encoding{name: "cl100k_base", pattern: cl100kPattern}
+	o200k  = &encoding{name: "o200k_base", pattern: o200kPattern}
+)
+
+// encodingFor returns the encoding of an OpenAI model, or nil for other
+// models
+func encodingFor(model string) *encoding {
+	if largeVocabulary(model) {
+		return o200k
+	}
+	for _, prefix := range []string{"gpt-4", "gpt-3.5", "gpt-35", "text-embedding"} {
+		if strings.HasPrefix(model, prefix) {
+			return cl100k
+		}
+	}
+	return nil
+}
+
+// count returns the number of tokens text encodes to, or false if the rank
+// table is unavailable
+func (e *encoding) count(text string) (int, bool) {
+	e.once.Do(e.load)
+	if e.ranks == nil {
+		return 0, false
+	}
+
+	n := 0
+	for _, piece := range e.split(text) {
+		if _, ok := e.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += mergeCount(e.ranks, piece)
+	}
+	return n, true
+}
+
+// split cuts text into the pieces that are encoded separately
+func (e *encoding) split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		piece := e.pattern.FindString(text)
+		if piece == "" {
+			// Invalid UTF-8 matches nothing; it is encoded byte by byte
+			_, size := utf8.DecodeRuneInString(text)
+			piece = text[:size]
+		}
+		// Whitespace before other text leaves its last character to start
+		// the next piece, as \s+(?!\S) does
+		if len(piece) < len(text) && isSpace(piece) && !strings.HasSuffix(piece, "\n") && !strings.HasSuffix(piece, "\r") {
+			next, _ := utf8.DecodeRuneInString(text[len(piece):])
+			if !unicode.IsSpace(next) {
+				if _, size := utf8.DecodeLastRuneInString(piece); size < len(piece) {
+					piece = piece[:len(piece)-size]
+				}
+			}
+		}
+		pieces = append(pieces, piece)
+		text = text[len(piece):]
+	}
+	return pieces
+}
+
+func isSpace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeCount applies the byte-pair merges to piece, always merging the
+// adjacent pair with the lowest rank first and the leftmost pair on ties,
+// and returns the number of parts left
+func mergeCount(ranks map[string]int, piece string) int {
+	if len(piece) < 2 {
+		return len(piece)
+	}
+
+	// The parts form a linked list, starting as single bytes
+	n := len(piece)
+	start := make([]int, n+1) // start[i] is the offset of part i, start[n] the end
+	next := make([]int, n)
+	prev := make([]int, n)
+	for i := 0; i < n; i++ {
+		start[i], next[i], prev[i] = i, i+1, i-1
+	}
+	start[n] = n
+	end := func(i int) int { return start[next[i]] }
+
+	pairs := &pairHeap{}
+	push := func(i int) {
+		if i < 0 || next[i] >= n {
+			return
+		}
+		if rank, ok := ranks[piece[start[i]:end(next[i])]]; ok {
+			heap.Push(pairs, pair{rank: rank, left: i, right: next[i], end: end(next[i])})
+		}
+	}
+	for i := 0; i < n-1; i++ {
+		push(i)
+	}
+
+	parts := n
+	for pairs.Len() > 0 {
+		p := heap.Pop(pairs).(pair)
+		// Skip pairs whose parts were merged since
+		if next[p.left] != p.right || p.right >= n || end(p.right) != p.end || prev[p.right] != p.left {
+			continue
+		}
+		next[p.left] = next[p.right]
+		if next[p.left] < n {
+			prev[next[p.left]] = p.left
+		}
+		prev[p.right] = -2 // merged away
+		parts--
+		push(prev[p.left])
+		push(p.left)
+	}
+	return parts
+}
+
+// pair is two adjacent parts that may be merged
+type pair struct {
+	rank        int
+	left, right int // the parts
+	end         int // where right ended when the pair was found
+}
+
+type pairHeap []pair
+
+func (h pairHeap) Len() int { return len(h) }
+func (h pairHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].left < h[j].left
+}
+func (h pairHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pairHeap) Push(x interface{}) { *h = append(*h, x.(pair)) }
+func (h *pairHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// load reads the rank table, downloading it first if needed. The table
+// stays unavailable if that fails.
+func (e *encoding) load() {
+	path := filepath.Join(tokenizersDir(), e.name+".tiktoken")
+	if data, err := os.ReadFile(path); err == nil {
+		e.ranks, _ = parseRanks(data)
+		return
+	}
+	e.ranks, _ = e.download(path)
+}
+
+// download fetches the rank table and saves it to path. After a failed
+// download, no other is tried for a while so that runs without network
+// access are not slowed down.
+func (e *encoding) download(path string) (map[string]int, error) {
+	if downloadURL == "" {
+		return nil, fmt.Errorf("downloading tokenizers is disabled")
+	}
+	failed := path + ".failed"
+	if info, err := os.Stat(failed); err == nil && time.Since(info.ModTime()) < retryDownloadAfter {
+		return nil, fmt.Errorf("downloading %s failed recently", e.name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := fetchRanks(downloadURL + e.name + ".tiktoken")
+	var ranks map[string]int
+	if err == nil {
+		ranks, err = parseRanks(data)
+	}
+	if err != nil {
+		os.WriteFile(failed, nil, 0600)
+		return nil, err
+	}
+	os.Remove(failed)
+	if err := config.WriteFileAtomic(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+func fetchRanks(url string) ([]byte, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseRanks parses a rank table: one base64-encoded token and its rank
+// per line. Every byte must be a token and no rank may repeat, which
+// catches truncated or corrupted files.
+func parseRanks(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid rank table line %q", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank table line %q: %v", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil || rank < 0 || seen[rank] {
+			return nil, fmt.Errorf("invalid rank table line %q", line)
+		}
+		seen[rank] = true
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for b := 0; b < 256; b++ {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("rank table lacks byte %d", b)
+		}
+	}
+	return ranks, nil
+}
@@ -0,0 +1,205 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Never download rank tables while testing; the tests that need one
+	// write their own
+	dir, err := os.MkdirTemp("", "tokenizers")
+	if err != nil {
+		panic(err)
+	}
+	tokenizersDir = func() string { return dir }
+	downloadURL = ""
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testRanks returns a rank table with every byte and the given merges,
+// ranked in order after the bytes
+func testRanks(merges ...string) map[string]int {
+	ranks := make(map[string]int)
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	for i, merge := range merges {
+		ranks[merge] = 256 + i
+	}
+	return ranks
+}
+
+// rankFile formats ranks as a tiktoken rank table
+func rankFile(ranks map[string]int) []byte {
+	var lines []string
+	for token, rank := range ranks {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(token)), rank))
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// loaded returns an encoding using ranks
+func loaded(pattern *encoding, ranks map[string]int) *encoding {
+	enc := &encoding{name: pattern.name, pattern: pattern.pattern}
+	enc.once.Do(func() {})
+	enc.ranks = ranks
+	return enc
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		enc  *encoding
+		text string
+		want []string
+	}{
+		{cl100k, "hello world", []string{"hello", " world"}},
+		{cl100k, "  x", []string{" ", " x"}},
+		{cl100k, "a  \n\n  b", []string{"a", "  \n\n", " ", " b"}},
+		{cl100k, "x  ", []string{"x", "  "}},
+		{cl100k, "don't", []string{"don", "'t"}},
+		{cl100k, "I'm", []string{"I", "'m"}},
+		{cl100k, "1234567", []string{"123", "456", "7"}},
+		{cl100k, "foo!!!\n", []string{"foo", "!!!\n"}},
+		{cl100k, "a  b", []string{"a", " ", " b"}},
+		{cl100k, "日本語です", []string{"日本語です"}},
+		{o200k, "HelloWorld", []string{"Hello", "World"}},
+		{o200k, "I'm", []string{"I'm"}},
+		{o200k, "a/b/\n", []string{"a", "/b", "/\n"}},
+	}
+	for _, test := range tests {
+		got := test.enc.split(test.text)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", test.want) {
+			t.Errorf("%s split %q = %q, want %q", test.enc.name, test.text, got, test.want)
+		}
+	}
+}
+
+func TestMergeCount(t *testing.T) {
+	tests := []struct {
+		piece  string
+		merges []string
+		want   int
+	}{
+		{"a", nil, 1},
+		{"abcd", nil, 4},
+		// The lowest rank merges first, so bc wins over ab
+		{"abcd", []string{"bc", "ab", "cd", "bcd"}, 2},
+		{"abcd", []string{"ab", "bc", "cd", "abcd"}, 1},
+		// Equal pairs merge from the left
+		{"aaa", []string{"aa"}, 2},
+		{"aaaa", []string{"aa", "aaaa"}, 1},
+		{"aaaaa", []string{"aa", "aaaa"}, 2},
+		{strings.Repeat("ab", 1000), []string{"ab", "abab"}, 500},
+	}
+	for _, test := range tests {
+		if got := mergeCount(testRanks(test.merges...), test.piece); got != test.want {
+			t.Errorf("mergeCount(%.10q, %v) = %d, want %d", test.piece, test.merges, got, test.want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	enc := loaded(cl100k, testRanks("he", "ll", "hell", "hello", " w", "or", " wor", "ld", " world"))
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"hello  world", 3},
+		{"hello wold", 1 + 3},
+	}
+	for _, test := range tests {
+		if got, ok := enc.count(test.text); !ok || got != test.want {
+			t.Errorf("count(%q) = %d, %v; want %d", test.text, got, ok, test.want)
+		}
+	}
+}
+
+func TestLoadDownloadsOnce(t *testing.T) {
+	dir := t.TempDir()
+	oldDir, oldURL := tokenizersDir, downloadURL
+	defer func() { tokenizersDir, downloadURL = oldDir, oldURL }()
+	tokenizersDir = func() string { return dir }
+
+	requests := 0
+	table := rankFile(testRanks("ab"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/cl100k_base.tiktoken" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(table)
+	}))
+	defer srv.Close()
+	downloadURL = srv.URL + "/"
+
+	for i := 0; i < 2; i++ {
+		enc := &encoding{name: cl100k.name, pattern: cl100k.pattern}
+		if got, ok := enc.count("ab"); !ok || got != 1 {
+			t.Fatalf("count(ab) = %d, %v; want 1 with the downloaded table", got, ok)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("downloaded %d times, want once and then read from the file", requests)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cl100k_base.tiktoken")); err != nil {
+		t.Errorf("table not saved: %v", err)
+	}
+
+	// A failed download falls back to the estimate and is not retried soon
+	for i := 0; i < 2; i++ {
+		enc := &encoding{name: "missing", pattern: cl100k.pattern}
+		if _, ok := enc.count("ab"); ok {
+			t.Fatal("count succeeded without a table")
+		}
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want a single one for the missing table", requests-1)
+	}
+}
+
+func TestParseRanksRejectsBadTables(t *testing.T) {
+	tests := map[string][]byte{
+		"missing byte":   rankFile(map[string]int{"a": 0}),
+		"repeated rank":  append(rankFile(testRanks()), []byte("YWI= 5\n")...),
+		"malformed line": append(rankFile(testRanks()), []byte("YWI=\n")...),
+		"not base64":     append(rankFile(testRanks()), []byte("!!! 300\n")...),
+	}
+	for name, data := range tests {
+		if _, err := parseRanks(data); err == nil {
+			t.Errorf("%s: parseRanks succeeded", name)
+		}
+	}
+	if _, err := parseRanks(rankFile(testRanks("ab"))); err != nil {
+		t.Errorf("valid table rejected: %v", err)
+	}
+}
+
+func TestBudgetWithoutMarginWhenExact(t *testing.T) {
+	old := o200k
+	defer func() { o200k = old }()
+	o200k = loaded(o200k, testRanks())
+
+	if !Exact("gpt-4o") {
+		t.Fatal("gpt-4o not counted exactly with its table loaded")
+	}
+	if got, want := Budget("gpt-4o", 0), 128000-8192; got != want {
+		t.Errorf("gpt-4o budget = %d, want %d", got, want)
+	}
+	if Exact("claude-sonnet-4-5") {
+		t.Error("Claude counted exactly")
+	}
+}
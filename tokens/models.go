@@ -0,0 +1,17 @@
+package tokens
+
+import "ask/config"
+
+const (
+	// maxOutputReserve caps the part of the context window kept free for
+	// the model's answer
+	maxOutputReserve = 8192
+	// estimateMargin is the percentage of the remaining window left unused
+	// because Estimate can count low
+	estimateMargin = 20
+)
+
+// ContextWindow returns the context window size of a model in tokens
+func ContextWindow(model string) int {
+	return config.LookupModel(model).ContextWindow
+}
@@ -0,0 +1,186 @@
+package tokens
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ask/config"
+)
+
+// pretokenize splits text the way cl100k_base and o200k_base do before
+// applying byte-pair merges. Go's regexp has no lookahead, so trailing
+// whitespace is not split off exactly as the real pattern does.
+var pretokenize = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+const (
+	// perMessageTokens covers the role and delimiters wrapped around every
+	// message by the chat format
+	perMessageTokens = 4
+	// replyPrimingTokens covers the tokens that start the assistant's reply
+	replyPrimingTokens = 3
+)
+
+// Estimate returns the number of tokens text encodes to. OpenAI models are
+// counted with their byte-pair encoding. Other models, and OpenAI models
+// while the encoding's rank table is unavailable, get an approximation:
+// text is split into the same pieces as the OpenAI tokenizers and the
+// merges within each piece are estimated, so that common short words are a
+// single token and longer runs split every few bytes. Code and non-Latin
+// text can come out low, which Budget leaves a margin for.
+func Estimate(model, text string) int {
+	if enc := encodingFor(model); enc != nil {
+		if n, ok := enc.count(text); ok {
+			return n
+		}
+	}
+
+	chunk := 4
+	if largeVocabulary(model) {
+		chunk = 5
+	}
+
+	count := 0
+	for _, piece := range pretokenize.FindAllString(text, -1) {
+		count += estimatePiece(piece, chunk)
+	}
+	return count
+}
+
+// Exact reports whether Estimate counts the tokens of model exactly rather
+// than approximating them
+func Exact(model string) bool {
+	enc := encodingFor(model)
+	if enc == nil {
+		return false
+	}
+	_, ok := enc.count("")
+	return ok
+}
+
+// estimatePiece estimates the tokens in one pre-tokenized piece
+func estimatePiece(piece string, chunk int) int {
+	trimmed := strings.TrimLeft(piece, " ")
+	if trimmed == "" {
+		return 1
+	}
+
+	r, _ := utf8.DecodeRuneInString(trimmed)
+	switch {
+	case unicode.IsLetter(r):
+		if !isASCII(trimmed) {
+			// Non-Latin scripts average roughly one token per character
+			return utf8.RuneCountInString(trimmed)
+		}
+		if len(trimmed) <= chunk+2 {
+			return 1
+		}
+		return 1 + (len(trimmed)-2)/chunk
+	case unicode.IsDigit(r):
+		return 1
+	case unicode.IsSpace(r):
+		return 1
+	default:
+		// Runs of punctuation and symbols merge less often
+		return (utf8.RuneCountInString(trimmed) + 1) / 2
+	}
+}
+
+// EstimateMessages approximates the prompt tokens used by a list of messages
+func EstimateMessages(model string, messages []config.ChatMessage) int {
+	total := replyPrimingTokens
+	for _, msg := range messages {
+		total += messageTokens(model, msg)
+	}
+	return total
+}
+
+// messageTokens estimates the tokens of a single message, including any
+// tool calls
+func messageTokens(model string, msg config.ChatMessage) int {
+	n := perMessageTokens + Estimate(model, msg.Role) + Estimate(model, msg.Content)
+	for _, call := range msg.ToolCalls {
+		n += Estimate(model, call.Function.Name) + Estimate(model, call.Function.Arguments)
+	}
+	return n
+}
+
+// Fit drops the oldest turns from messages until they fit within budget
+// tokens. System messages and the newest message are always kept. Turns are
+// dropped whole, from a user message up to the next one, and the number of
+// dropped turns is returned.
+func Fit(model string, messages []config.ChatMessage, budget int) ([]config.ChatMessage, int) {
+	if budget <= 0 || EstimateMessages(model, messages) <= budget {
+		return messages, 0
+	}
+
+	var system, history []config.ChatMessage
+	for i, msg := range messages {
+		if msg.Role == "system" && i < len(messages)-1 {
+			system = append(system, msg)
+		} else {
+			history = append(history, msg)
+		}
+	}
+
+	used := EstimateMessages(model, system)
+	for _, msg := range history {
+		used += messageTokens(model, msg)
+	}
+
+	dropped := 0
+	for used > budget && len(history) > 1 {
+		// Remove one turn: the first message and any replies before the
+		// next user message, but never the newest message
+		end := 1
+		for end < len(history)-1 && history[end].Role != "user" {
+			end++
+		}
+		for _, msg := range history[:end] {
+			used -= messageTokens(model, msg)
+		}
+		history = history[end:]
+		dropped++
+	}
+
+	return append(system, history...), dropped
+}
+
+// Budget returns the prompt token budget for model. A configured limit is
+// used as is; otherwise it is the model's context window less room for the
+// answer and, unless the model's tokens are counted exactly, a safety
+// margin for estimates that count low.
+func Budget(model string, configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	window := ContextWindow(model)
+	reserve := window / 4
+	if reserve > maxOutputReserve {
+		reserve = maxOutputReserve
+	}
+	budget := window - reserve
+	if Exact(model) {
+		return budget
+	}
+	return budget - budget*estimateMargin/100
+}
+
+func largeVocabulary(model string) bool {
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "chatgpt-4o", "gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
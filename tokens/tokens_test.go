@@ -0,0 +1,119 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+
+	"ask/config"
+)
+
+func turn(question, answer string) []config.ChatMessage {
+	return []config.ChatMessage{
+		{Role: "user", Content: question},
+		{Role: "assistant", Content: answer},
+	}
+}
+
+func TestFitUnderBudget(t *testing.T) {
+	messages := append(turn("hello", "hi"), config.ChatMessage{Role: "user", Content: "again"})
+	got, dropped := Fit("gpt-4o", messages, 10000)
+	if dropped != 0 || len(got) != len(messages) {
+		t.Errorf("Fit dropped %d turns, kept %d messages; want everything kept", dropped, len(got))
+	}
+}
+
+func TestFitDropsOldestTurns(t *testing.T) {
+	long := strings.Repeat("lorem ipsum dolor sit amet ", 40)
+	var messages []config.ChatMessage
+	messages = append(messages, config.ChatMessage{Role: "system", Content: "Be brief."})
+	for i := 0; i < 5; i++ {
+		messages = append(messages, turn(long, long)...)
+	}
+	messages = append(messages, config.ChatMessage{Role: "user", Content: "newest question"})
+
+	// Room for the system prompt, the newest question and two turns
+	budget := EstimateMessages("gpt-4o", messages[:1])
+	for _, msg := range messages[len(messages)-5:] {
+		budget += messageTokens("gpt-4o", msg)
+	}
+	got, dropped := Fit("gpt-4o", messages, budget)
+
+	if dropped != 3 {
+		t.Errorf("dropped %d turns, want 3", dropped)
+	}
+	if len(got) != 1+2*2+1 {
+		t.Fatalf("kept %d messages, want 6", len(got))
+	}
+	if got[0].Role != "system" || got[0].Content != "Be brief." {
+		t.Errorf("first message = %+v, want the system prompt", got[0])
+	}
+	if last := got[len(got)-1]; last.Content != "newest question" {
+		t.Errorf("last message = %+v, want the newest question", last)
+	}
+	if got[1].Role != "user" {
+		t.Errorf("history starts with %s, want a whole turn", got[1].Role)
+	}
+	if EstimateMessages("gpt-4o", got) > budget {
+		t.Errorf("kept %d tokens, over the budget of %d", EstimateMessages("gpt-4o", got), budget)
+	}
+}
+
+func TestFitKeepsNewestMessage(t *testing.T) {
+	huge := strings.Repeat("word ", 5000)
+	messages := []config.ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "old"},
+		{Role: "assistant", Content: "reply"},
+		{Role: "user", Content: huge},
+	}
+	got, dropped := Fit("gpt-4o", messages, 100)
+	if dropped != 1 || len(got) != 2 || got[0].Role != "system" || got[1].Content != huge {
+		t.Errorf("Fit = %d messages after dropping %d turns; want the system prompt and the newest message", len(got), dropped)
+	}
+}
+
+func TestFitDropsToolCallsWithTheirTurn(t *testing.T) {
+	long := strings.Repeat("data ", 200)
+	messages := []config.ChatMessage{
+		{Role: "user", Content: "read it"},
+		{Role: "assistant", ToolCalls: []config.ToolCall{{ID: "1", Function: config.FunctionCall{Name: "read_file", Arguments: `{"path":"a"}`}}}},
+		{Role: "tool", Content: long, ToolCallID: "1"},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "next"},
+	}
+	got, dropped := Fit("gpt-4o", messages, 50)
+	if dropped != 1 || len(got) != 1 || got[0].Content != "next" {
+		t.Errorf("Fit = %+v after dropping %d turns; want only the newest message", got, dropped)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	if got := Budget("gpt-4o", 5000); got != 5000 {
+		t.Errorf("configured budget = %d, want 5000", got)
+	}
+	// 128000 less 8192 for the answer, less the 20% margin
+	if got, want := Budget("gpt-4o", 0), 119808-119808/5; got != want {
+		t.Errorf("gpt-4o budget = %d, want %d", got, want)
+	}
+	// Small windows keep a quarter for the answer
+	if got, want := Budget("gpt-4", 0), 6144-6144/5; got != want {
+		t.Errorf("gpt-4 budget = %d, want %d", got, want)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	if got := Estimate("gpt-4o", ""); got != 0 {
+		t.Errorf("Estimate of nothing = %d", got)
+	}
+	if got := Estimate("gpt-4o", "Hello world"); got != 2 {
+		t.Errorf("Estimate(Hello world) = %d, want 2", got)
+	}
+	// Non-Latin text is about a token per character
+	if got := Estimate("gpt-4o", "こんにちは"); got != 5 {
+		t.Errorf("Estimate(こんにちは) = %d, want 5", got)
+	}
+	short, long := Estimate("gpt-4", "a b c"), Estimate("gpt-4", strings.Repeat("a b c ", 100))
+	if long <= short {
+		t.Errorf("longer text estimated at %d tokens, not more than %d", long, short)
+	}
+}
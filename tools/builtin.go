@@ -0,0 +1,452 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ask/config"
+	"ask/input"
+)
+
+const (
+	// maxEntries limits the entries list_dir returns
+	maxEntries = 500
+	// maxCommandOutput limits the output run_command returns
+	maxCommandOutput = 32 << 10
+	// commandTimeout limits how long run_command may run
+	commandTimeout = 30 * time.Second
+)
+
+// DefaultAllowedCommands are the commands run_command may run unless the
+// configuration lists others. An entry allows any command line starting
+// with its words.
+var DefaultAllowedCommands = []string{
+	"ls", "pwd", "cat", "head", "tail", "wc", "grep", "rg", "find", "file",
+	"git status", "git log", "git diff", "git show", "git branch",
+	"go version", "go env", "go list",
+}
+
+var builtins = []*Tool{
+	{
+		Name:          "read_file",
+		Description:   "Read a text file from the local file system.",
+		Parameters:    `{"type":"object","properties":{"path":{"type":"string","description":"Path of the file, relative to the working directory. Files outside it cannot be read."}},"required":["path"]}`,
+		DefaultPolicy: PolicyAsk,
+		Check:         checkPath,
+		Run:           readFile,
+	},
+	{
+		Name:          "list_dir",
+		Description:   "List the files and directories in a local directory.",
+		Parameters:    `{"type":"object","properties":{"path":{"type":"string","description":"Path of the directory, relative to the working directory. Defaults to the working directory. Directories outside it cannot be listed."}}}`,
+		DefaultPolicy: PolicyAlways,
+		Check:         checkPath,
+		Run:           listDir,
+	},
+	{
+		Name:          "run_command",
+		Description:   "Run a command from an allow-list and return its output. No shell is involved, so pipes, redirection and variables are not available.",
+		Parameters:    `{"type":"object","properties":{"command":{"type":"string","description":"The command line, e.g. \"git log -n 5\""}},"required":["command"]}`,
+		DefaultPolicy: PolicyAsk,
+		Check:         checkCommand,
+		Run:           runCommand,
+	},
+}
+
+type pathArgs struct {
+	Path string `json:"path"`
+}
+
+// checkPath refuses path arguments outside the working directory
+func checkPath(r *Runner, raw json.RawMessage) error {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	if args.Path == "" {
+		return nil
+	}
+	_, err := resolvePath(args.Path)
+	return err
+}
+
+// resolvePath returns the real path of a path argument, with symbolic
+// links followed. Paths outside the working directory are refused, and so
+// are paths in the configuration directory, which holds the API keys.
+func resolvePath(path string) (string, error) {
+	real, err := realPath(path, true)
+	if err != nil {
+		return "", err
+	}
+	if err := checkContained(path, real); err != nil {
+		return "", err
+	}
+	return real, nil
+}
+
+// realPath returns the absolute path of path with symbolic links followed.
+// Unless it must exist, a path that does not is returned cleaned.
+func realPath(path string, mustExist bool) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs := path
+	if !filepath.IsAbs(path) {
+		// Not joined, so that ".." follows symbolic links as the commands do
+		abs = wd + string(filepath.Separator) + path
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		if mustExist || !os.IsNotExist(err) {
+			return "", err
+		}
+		if real, err = filepath.EvalSymlinks(wd); err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(path) {
+			return filepath.Join(real, path), nil
+		}
+		return filepath.Clean(path), nil
+	}
+	return real, nil
+}
+
+// checkContained refuses real, the real path of path, if it is outside the
+// working directory or in the configuration directory
+func checkContained(path, real string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if wd, err = filepath.EvalSymlinks(wd); err != nil {
+		return err
+	}
+	if !within(wd, real) {
+		return fmt.Errorf("%s is outside the working directory", path)
+	}
+	configDir := filepath.Dir(config.GetConfigPath())
+	if resolved, err := filepath.EvalSymlinks(configDir); err == nil {
+		configDir = resolved
+	}
+	if within(configDir, real) {
+		return fmt.Errorf("%s is in the ask configuration directory", path)
+	}
+	return nil
+}
+
+// checkPathArgument applies the read_file restrictions to an argument of a
+// command that reads files. Options may name a file too, as in --file=x or
+// -fx; other arguments that are not paths, such as patterns, pass as long as
+// they don't look like paths outside the working directory.
+func checkPathArgument(arg string) error {
+	path := arg
+	if strings.HasPrefix(arg, "-") {
+		if i := strings.Index(arg, "="); i >= 0 {
+			path = arg[i+1:]
+		} else if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			path = arg[2:]
+		} else {
+			return nil
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	real, err := realPath(path, false)
+	if err != nil {
+		return fmt.Errorf("%s: %v", arg, err)
+	}
+	return checkContained(arg, real)
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func readFile(ctx context.Context, r *Runner, raw json.RawMessage) (string, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil || args.Path == "" {
+		return "", fmt.Errorf("read_file needs a path")
+	}
+	path, err := resolvePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; use list_dir", args.Path)
+	}
+
+	// Read the one file named, even if its name looks like a glob pattern
+	if info.Size() > r.maxBytes {
+		return "", fmt.Errorf("%s is larger than the maximum of %s (set max_input_bytes in the config to raise it)", args.Path, input.FormatSize(r.maxBytes))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if input.IsBinary(data) {
+		return "", fmt.Errorf("%s appears to be a binary file", args.Path)
+	}
+	return string(data), nil
+}
+
+func listDir(ctx context.Context, r *Runner, raw json.RawMessage) (string, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	path, err := resolvePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var out strings.Builder
+	for i, entry := range entries {
+		if i == maxEntries {
+			fmt.Fprintf(&out, "... and %d more\n", len(entries)-maxEntries)
+			break
+		}
+		if entry.IsDir() {
+			fmt.Fprintf(&out, "%s/\n", entry.Name())
+			continue
+		}
+		size := ""
+		if info, err := entry.Info(); err == nil {
+			size = " (" + input.FormatSize(info.Size()) + ")"
+		}
+		fmt.Fprintf(&out, "%s%s\n", entry.Name(), size)
+	}
+	if out.Len() == 0 {
+		return filepath.Clean(args.Path) + " is empty", nil
+	}
+	return out.String(), nil
+}
+
+// commandArgs returns the arguments of a run_command call if it may run
+func commandArgs(r *Runner, raw json.RawMessage) ([]string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || strings.TrimSpace(args.Command) == "" {
+		return nil, fmt.Errorf("run_command needs a command")
+	}
+	argv, err := splitCommand(args.Command)
+	if err != nil {
+		return nil, err
+	}
+	if !r.isAllowed(argv) {
+		return nil, fmt.Errorf("%q is not an allowed command (allowed: %s)", args.Command, strings.Join(r.allowed, ", "))
+	}
+	if err := checkArguments(argv); err != nil {
+		return nil, err
+	}
+	return argv, nil
+}
+
+// branchListFlags are the git branch options that only list branches
+var branchListFlags = map[string]bool{
+	"-a": true, "--all": true, "-r": true, "--remotes": true,
+	"-v": true, "-vv": true, "--verbose": true, "--show-current": true,
+	"--no-color": true, "--color": true,
+}
+
+// fileCommands are the allowed commands that read the files and
+// directories named in their arguments
+var fileCommands = map[string]bool{
+	"ls": true, "cat": true, "head": true, "tail": true, "wc": true,
+	"grep": true, "rg": true, "find": true, "file": true,
+}
+
+// goFlags are the go options that change the Go environment or run other
+// programs
+var goFlags = map[string]bool{
+	"w": true, "u": true, "toolexec": true, "exec": true, "overlay": true,
+}
+
+// checkArguments rejects the options that make an otherwise read-only
+// command write, delete or run other programs, and paths that read_file
+// would refuse. Allowing a command by its leading words would let these
+// through.
+func checkArguments(argv []string) error {
+	args := argv[1:]
+	if fileCommands[argv[0]] {
+		for _, arg := range args {
+			if err := checkPathArgument(arg); err != nil {
+				return fmt.Errorf("%s: %v", argv[0], err)
+			}
+		}
+	}
+
+	switch argv[0] {
+	case "find":
+		for _, arg := range args {
+			if arg == "-delete" || arg == "-fls" || strings.HasPrefix(arg, "-exec") ||
+				strings.HasPrefix(arg, "-ok") || strings.HasPrefix(arg, "-fprint") {
+				return fmt.Errorf("find %s is not allowed", arg)
+			}
+		}
+	case "rg":
+		for _, arg := range args {
+			if arg == "--pre" || strings.HasPrefix(arg, "--pre=") {
+				return fmt.Errorf("rg --pre is not allowed")
+			}
+		}
+	case "git":
+		if len(args) == 0 {
+			return nil
+		}
+		switch args[0] {
+		case "diff", "log", "show":
+			for _, arg := range args[1:] {
+				if arg == "-o" || arg == "--output" || strings.HasPrefix(arg, "--output=") {
+					return fmt.Errorf("git %s %s is not allowed", args[0], arg)
+				}
+			}
+		case "branch":
+			// Without --list, a name creates a branch
+			listing := false
+			for _, arg := range args[1:] {
+				switch {
+				case arg == "--list" || arg == "-l":
+					listing = true
+				case branchListFlags[arg]:
+				case listing && !strings.HasPrefix(arg, "-"):
+				default:
+					return fmt.Errorf("git branch %s is not allowed; only listing branches is", arg)
+				}
+			}
+		}
+	case "go":
+		for _, arg := range args {
+			name := strings.TrimLeft(arg, "-")
+			if i := strings.Index(name, "="); i >= 0 {
+				name = name[:i]
+			}
+			if strings.HasPrefix(arg, "-") && goFlags[name] {
+				return fmt.Errorf("go %s is not allowed", arg)
+			}
+		}
+	}
+	return nil
+}
+
+func checkCommand(r *Runner, raw json.RawMessage) error {
+	_, err := commandArgs(r, raw)
+	return err
+}
+
+func runCommand(ctx context.Context, r *Runner, raw json.RawMessage) (string, error) {
+	argv, err := commandArgs(r, raw)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+
+	result := out.String()
+	if len(result) > maxCommandOutput {
+		result = result[:maxCommandOutput] + "\n[... output truncated ...]"
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Sprintf("%s\nExit status: %d", result, exitErr.ExitCode()), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// isAllowed reports whether argv starts with the words of an allowed command
+func (r *Runner) isAllowed(argv []string) bool {
+	for _, allowed := range r.allowed {
+		words := strings.Fields(allowed)
+		if len(words) == 0 || len(words) > len(argv) {
+			continue
+		}
+		match := true
+		for i, word := range words {
+			if argv[i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommand splits a command line into arguments, honouring quotes.
+// Shell operators are rejected since no shell runs the command.
+func splitCommand(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case strings.ContainsRune("|&;<>`$(){}\n", c):
+			return nil, fmt.Errorf("shell syntax (%q) is not supported; run a single command", c)
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckArguments(t *testing.T) {
+	tests := []struct {
+		command string
+		ok      bool
+	}{
+		{"find . -name *.go", true},
+		{"find . -delete", false},
+		{"find . -exec rm x", false},
+		{"find . -execdir rm x", false},
+		{"find . -ok rm x", false},
+		{"find . -fprint out.txt", false},
+		{"find . -fprintf out.txt %p", false},
+		{"find . -fls out.txt", false},
+		{"rg TODO", true},
+		{"rg --pre cat TODO", false},
+		{"rg --pre=cat TODO", false},
+		{"git log -n 5", true},
+		{"git diff --output=patch.diff", false},
+		{"git diff --output patch.diff", false},
+		{"git show -o out HEAD", false},
+		{"git log --output=log.txt", false},
+		{"git branch", true},
+		{"git branch -a -v", true},
+		{"git branch --list feature*", true},
+		{"git branch -D main", false},
+		{"git branch -m old new", false},
+		{"git branch new-branch", false},
+		{"git status", true},
+		{"go version", true},
+		{"go env GOPATH", true},
+		{"go env -w GOFLAGS=-mod=mod", false},
+		{"go env -u GOFLAGS", false},
+		{"go list ./...", true},
+		{"go list -toolexec=prog ./...", false},
+		{"go list -toolexec prog ./...", false},
+		{"go list --toolexec=prog ./...", false},
+		{"go list -exec prog ./...", false},
+		{"go list -overlay=overlay.json ./...", false},
+		{"cat builtin.go", true},
+		{"cat missing.txt", true},
+		{"head -n 5 builtin.go", true},
+		{"grep -rn TODO .", true},
+		{"cat /etc/passwd", false},
+		{"cat ../go.mod", false},
+		{"tail -n 5 ../go.mod", false},
+		{"grep -f/etc/passwd x", false},
+		{"grep --file=/etc/passwd x", false},
+		{"rg TODO /", false},
+		{"find / -name x", false},
+		{"find . -name x -newer ../go.mod", false},
+		{"file ..", false},
+		{"ls ../..", false},
+		{"wc -l sub/../../go.mod", false},
+	}
+	for _, test := range tests {
+		argv, err := splitCommand(test.command)
+		if err != nil {
+			t.Fatalf("splitCommand(%q): %v", test.command, err)
+		}
+		err = checkArguments(argv)
+		if (err == nil) != test.ok {
+			t.Errorf("checkArguments(%q) = %v, want ok %v", test.command, err, test.ok)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.MkdirAll(filepath.Join(work, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(work, "sub", "a.txt"), filepath.Join(root, "secret.txt")} {
+		if err := os.WriteFile(name, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(work, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{".", true},
+		{"sub/a.txt", true},
+		{filepath.Join(work, "sub", "a.txt"), true},
+		{"sub/../sub/a.txt", true},
+		{"../secret.txt", false},
+		{filepath.Join(root, "secret.txt"), false},
+		{"link.txt", false},
+		{"/", false},
+	}
+	for _, test := range tests {
+		_, err := resolvePath(test.path)
+		if (err == nil) != test.ok {
+			t.Errorf("resolvePath(%q) = %v, want ok %v", test.path, err, test.ok)
+		}
+		// Commands that read files get the same check
+		if err := checkArguments([]string{"cat", test.path}); (err == nil) != test.ok {
+			t.Errorf("checkArguments(cat %q) = %v, want ok %v", test.path, err, test.ok)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	work := t.TempDir()
+	files := map[string]string{"a[1].txt": "literal", "a1.txt": "glob match", "big.txt": "0123456789", "bin.dat": "a\x00b"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	r := &Runner{maxBytes: 8}
+	tests := []struct {
+		path string
+		want string // empty when reading fails
+	}{
+		{"a[1].txt", "literal"},
+		{"a?.txt", ""},
+		{"*.txt", ""},
+		{"big.txt", ""},
+		{"bin.dat", ""},
+	}
+	for _, test := range tests {
+		got, err := readFile(context.Background(), r, json.RawMessage(`{"path":"`+test.path+`"}`))
+		if test.want == "" && err == nil {
+			t.Errorf("read_file %s = %q, want an error", test.path, got)
+		}
+		if test.want != "" && (err != nil || got != test.want) {
+			t.Errorf("read_file %s = %q, %v; want %q", test.path, got, err, test.want)
+		}
+	}
+}
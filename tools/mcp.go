@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"ask/mcp"
+)
+
+// invalidName matches characters not allowed in function names
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// maxNameLength is the longest function name the APIs accept
+const maxNameLength = 64
+
+// AddMCP offers the tools of an MCP server to the model, named
+// <server>__<tool>. policy is the default policy for them; the
+// configured per-tool policies still apply. Close stops the server.
+func (r *Runner) AddMCP(ctx context.Context, client *mcp.Client, policy string) error {
+	if policy == "" {
+		policy = PolicyAsk
+	}
+	if !IsValidPolicy(policy) {
+		return fmt.Errorf("invalid policy %q for MCP server %s (use always, ask or never)", policy, client.Name)
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return fmt.Errorf("MCP server %s: %v", client.Name, err)
+	}
+	r.servers = append(r.servers, client)
+
+	for _, tool := range tools {
+		full := client.Name + "__" + tool.Name
+		name := invalidName.ReplaceAllString(full, "_")
+		if _, taken := r.tools[name]; taken || len(name) > maxNameLength {
+			name = uniqueName(name, full)
+		}
+		parameters := string(tool.InputSchema)
+		if len(tool.InputSchema) == 0 || string(tool.InputSchema) == "null" {
+			parameters = `{"type":"object","properties":{}}`
+		}
+		remote := tool.Name
+		r.Register(&Tool{
+			Name:          name,
+			Description:   tool.Description,
+			Parameters:    parameters,
+			DefaultPolicy: policy,
+			Run: func(ctx context.Context, r *Runner, args json.RawMessage) (string, error) {
+				return client.CallTool(ctx, remote, args)
+			},
+		})
+	}
+	return nil
+}
+
+// uniqueName shortens name if needed and adds a hash of the tool's full
+// name, so that tools whose names were truncated or had characters replaced
+// don't replace each other
+func uniqueName(name, full string) string {
+	sum := sha256.Sum256([]byte(full))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	if len(name) > maxNameLength-len(suffix) {
+		name = name[:maxNameLength-len(suffix)]
+	}
+	return name + suffix
+}
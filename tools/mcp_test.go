@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"ask/config"
+	"ask/mcp"
+	"ask/mcp/mcptest"
+)
+
+func TestMain(m *testing.M) {
+	mcptest.Main()
+	os.Exit(m.Run())
+}
+
+// newMCPRunner returns a Runner with the tools of the fixture server,
+// added under the name server
+func newMCPRunner(t *testing.T, cfg *config.Config, server, policy string, confirm Confirm) *Runner {
+	t.Helper()
+	r, err := New(cfg, confirm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := mcp.Start(context.Background(), server, mcptest.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	if err := r.AddMCP(context.Background(), client, policy); err != nil {
+		client.Close()
+		t.Fatal(err)
+	}
+	return r
+}
+
+func call(r *Runner, name, arguments string) string {
+	return r.Call(context.Background(), config.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: config.FunctionCall{Name: name, Arguments: arguments},
+	}).Content
+}
+
+func TestAddMCPRouting(t *testing.T) {
+	r := newMCPRunner(t, &config.Config{}, "fix.server", PolicyAlways, nil)
+
+	offered := map[string]bool{}
+	for _, schema := range r.Schemas() {
+		offered[schema.Function.Name] = true
+	}
+	// Characters not allowed in function names are replaced
+	for _, name := range []string{"fix_server__echo", "fix_server__fail", "read_file"} {
+		if !offered[name] {
+			t.Errorf("%s not offered (offered: %v)", name, offered)
+		}
+	}
+
+	if got := call(r, "fix_server__echo", `{"text":"routed"}`); got != "routed" {
+		t.Errorf("echo = %q, want routed", got)
+	}
+	if got := call(r, "fix_server__fail", ""); got != "Error: it failed" {
+		t.Errorf("fail = %q, want the server's error", got)
+	}
+}
+
+func TestAddMCPPolicies(t *testing.T) {
+	cfg := &config.Config{Tools: &config.ToolsConfig{Policies: map[string]string{"fix__fail": PolicyNever}}}
+	asked := 0
+	r := newMCPRunner(t, cfg, "fix", PolicyAsk, func(string) string {
+		asked++
+		return "n"
+	})
+
+	for _, schema := range r.Schemas() {
+		if schema.Function.Name == "fix__fail" {
+			t.Error("fix__fail offered despite the never policy")
+		}
+	}
+	if got := call(r, "fix__echo", `{"text":"x"}`); got != "Error: the user declined to run fix__echo" || asked != 1 {
+		t.Errorf("echo = %q after %d confirmations, want declined after 1", got, asked)
+	}
+	if got := call(r, "fix__fail", ""); got != "Error: the user does not allow fix__fail" {
+		t.Errorf("fail = %q, want it refused", got)
+	}
+
+	if err := r.AddMCP(context.Background(), r.servers[0], "sometimes"); err == nil {
+		t.Error("AddMCP accepted an invalid policy")
+	}
+}
+
+func TestAddMCPNameClashes(t *testing.T) {
+	r := newMCPRunner(t, &config.Config{}, "fix", PolicyAlways, nil)
+
+	// Each tool gets its own name, within the length limit, and calls the
+	// tool it was named for
+	names := map[string]bool{}
+	for _, schema := range r.Schemas() {
+		name := schema.Function.Name
+		if !strings.HasPrefix(name, "fix__") {
+			continue
+		}
+		if len(name) > 64 {
+			t.Errorf("%s is longer than 64 characters", name)
+		}
+		names[name] = true
+	}
+	if len(names) != len(mcptest.Tools) {
+		t.Fatalf("offered %d MCP tools, want %d: %v", len(names), len(mcptest.Tools), names)
+	}
+
+	called := map[string]bool{}
+	for name := range names {
+		if strings.HasSuffix(name, "echo") || strings.HasSuffix(name, "fail") {
+			continue
+		}
+		called[call(r, name, "{}")] = true
+	}
+	for _, want := range []string{mcptest.LongName + "_one", mcptest.LongName + "_two", "get.item", "get_item"} {
+		if !called[want+" called"] {
+			t.Errorf("no tool calls %s (results: %v)", want, called)
+		}
+	}
+}
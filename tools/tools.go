@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ask/config"
+	"ask/mcp"
+	"ask/provider"
+)
+
+// Policies decide whether a tool call runs
+const (
+	PolicyAlways = "always" // run without asking
+	PolicyAsk    = "ask"    // ask for confirmation before each call
+	PolicyNever  = "never"  // do not offer the tool to the model
+)
+
+// MaxRounds limits how many times in a row the model may call tools before
+// giving its answer
+const MaxRounds = 10
+
+// Tool is a local function the model may call
+type Tool struct {
+	Name          string
+	Description   string
+	Parameters    string // JSON schema of the arguments
+	DefaultPolicy string
+	// Check, if set, rejects arguments before the user is asked to confirm
+	Check func(r *Runner, args json.RawMessage) error
+	Run   func(ctx context.Context, r *Runner, args json.RawMessage) (string, error)
+}
+
+// Confirm asks the user whether to run a tool call and returns their answer:
+// "y" to run it, "a" to run it and stop asking for this tool, anything else
+// to refuse
+type Confirm func(prompt string) string
+
+// Runner runs the tool calls of a model with the configured policies
+type Runner struct {
+	tools    map[string]*Tool
+	policies map[string]string
+	allowed  []string // commands run_command may run
+	maxBytes int64    // largest file read_file returns
+	confirm  Confirm  // nil when no one can be asked
+	servers  []*mcp.Client
+	// Notify reports each call as it runs
+	Notify func(message string)
+}
+
+// IsValidPolicy reports whether policy is a known policy
+func IsValidPolicy(policy string) bool {
+	return policy == PolicyAlways || policy == PolicyAsk || policy == PolicyNever
+}
+
+// New returns a Runner for the built-in tools, configured by cfg. confirm
+// may be nil, in which case calls needing confirmation are refused.
+func New(cfg *config.Config, confirm Confirm) (*Runner, error) {
+	r := &Runner{
+		tools:    map[string]*Tool{},
+		policies: map[string]string{},
+		allowed:  DefaultAllowedCommands,
+		maxBytes: cfg.GetMaxInputBytes(),
+		confirm:  confirm,
+		Notify:   func(string) {},
+	}
+	for _, tool := range builtins {
+		r.Register(tool)
+	}
+
+	if cfg.Tools != nil {
+		for name, policy := range cfg.Tools.Policies {
+			if !IsValidPolicy(policy) {
+				return nil, fmt.Errorf("invalid policy %q for tool %s (use always, ask or never)", policy, name)
+			}
+			r.policies[name] = policy
+		}
+		if len(cfg.Tools.AllowedCommands) > 0 {
+			r.allowed = cfg.Tools.AllowedCommands
+		}
+	}
+	return r, nil
+}
+
+// Close stops the MCP servers whose tools were added
+func (r *Runner) Close() {
+	mcp.CloseAll(r.servers)
+	r.servers = nil
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Runner) Register(tool *Tool) {
+	r.tools[tool.Name] = tool
+	if _, ok := r.policies[tool.Name]; !ok && tool.DefaultPolicy != "" {
+		r.policies[tool.Name] = tool.DefaultPolicy
+	}
+}
+
+// Policy returns the policy for a tool
+func (r *Runner) Policy(name string) string {
+	if policy, ok := r.policies[name]; ok {
+		return policy
+	}
+	return PolicyAsk
+}
+
+// Schemas returns the tools offered to the model, leaving out those with
+// the never policy
+func (r *Runner) Schemas() []provider.Tool {
+	var names []string
+	for name := range r.tools {
+		if r.Policy(name) != PolicyNever {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var schemas []provider.Tool
+	for _, name := range names {
+		tool := r.tools[name]
+		schemas = append(schemas, provider.Tool{
+			Type: "function",
+			Function: provider.ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  json.RawMessage(tool.Parameters),
+			},
+		})
+	}
+	return schemas
+}
+
+// Call runs a tool call and returns the tool message with its result.
+// Failures are reported to the model in the result rather than returned.
+func (r *Runner) Call(ctx context.Context, call config.ToolCall) config.ChatMessage {
+	result, err := r.call(ctx, call)
+	if err != nil {
+		result = "Error: " + err.Error()
+	}
+	return config.ChatMessage{
+		Role:       "tool",
+		Content:    result,
+		ToolCallID: call.ID,
+	}
+}
+
+func (r *Runner) call(ctx context.Context, call config.ToolCall) (string, error) {
+	name := call.Function.Name
+	tool, ok := r.tools[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(strings.TrimSpace(call.Function.Arguments)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "", fmt.Errorf("the arguments are not valid JSON")
+	}
+	if r.Policy(name) == PolicyNever {
+		return "", fmt.Errorf("the user does not allow %s", name)
+	}
+	if tool.Check != nil {
+		if err := tool.Check(r, args); err != nil {
+			return "", err
+		}
+	}
+	description := fmt.Sprintf("%s %s", name, compactJSON(args))
+
+	switch r.Policy(name) {
+	case PolicyAsk:
+		if r.confirm == nil {
+			return "", fmt.Errorf("%s needs confirmation, but there is no terminal to ask", name)
+		}
+		switch strings.ToLower(strings.TrimSpace(r.confirm(fmt.Sprintf("🔧 Allow %s? [y/N/a(lways)] ", description)))) {
+		case "y", "yes":
+		case "a", "always":
+			r.policies[name] = PolicyAlways
+		default:
+			return "", fmt.Errorf("the user declined to run %s", name)
+		}
+	default:
+		r.Notify("🔧 " + description)
+	}
+
+	return tool.Run(ctx, r, args)
+}
+
+// compactJSON shortens arguments for display
+func compactJSON(args json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(args, &v); err == nil {
+		if b, err := json.Marshal(v); err == nil {
+			args = b
+		}
+	}
+	s := string(args)
+	if len(s) > 120 {
+		s = s[:117] + "..."
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"ask/config"
+)
+
+// RunCommand runs ask usage [--by day|model|context] [--month YYYY-MM |
+// --days N | --all]
+func RunCommand(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	by := fs.String("by", "", "Only group by day, model or context")
+	month := fs.String("month", "", "Report a month, as YYYY-MM (default: this month)")
+	days := fs.Int("days", 0, "Report the last N days")
+	all := fs.Bool("all", false, "Report everything in the ledger")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *by != "" && *by != "day" && *by != "model" && *by != "context" {
+		return fmt.Errorf("invalid --by %q (use day, model or context)", *by)
+	}
+
+	now := time.Now()
+	since, until := MonthStart(now), time.Time{}
+	period := now.Format("January 2006")
+	switch {
+	case *all:
+		since, period = time.Time{}, "all time"
+	case *days > 0:
+		start := now.AddDate(0, 0, -(*days - 1))
+		since = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+		period = fmt.Sprintf("the last %d days", *days)
+	case *month != "":
+		start, err := time.ParseInLocation("2006-01", *month, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --month %q (use YYYY-MM)", *month)
+		}
+		since, until = start, start.AddDate(0, 1, 0)
+		period = start.Format("January 2006")
+	}
+
+	entries, err := Read(since)
+	if err != nil {
+		return err
+	}
+	if !until.IsZero() {
+		var inMonth []Entry
+		for _, entry := range entries {
+			if entry.Time.Before(until) {
+				inMonth = append(inMonth, entry)
+			}
+		}
+		entries = inMonth
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("📊 No usage recorded for %s.\n", period)
+		return nil
+	}
+	prices := LoadPrices(cfg)
+	report(os.Stdout, cfg, entries, prices, period, *by)
+	return nil
+}
+
+func report(w io.Writer, cfg *config.Config, entries []Entry, prices Prices, period, by string) {
+	fmt.Fprintf(w, "📊 Usage for %s\n\n", period)
+	if by == "" || by == "day" {
+		writeTable(w, "By day", Group(entries, prices, ByDay))
+	}
+	if by == "" || by == "model" {
+		writeTable(w, "By model", Group(entries, prices, ByModel))
+	}
+	if by == "" || by == "context" {
+		writeTable(w, "By context", Group(entries, prices, ByContext))
+	}
+
+	total := Sum(entries, prices)
+	fmt.Fprintf(w, "Total: %s, %s prompt and %s completion tokens, %s\n",
+		plural(total.Requests, "request"), FormatCount(total.PromptTokens), FormatCount(total.CompletionTokens), FormatCost(total.Cost))
+	if total.Unpriced > 0 {
+		fmt.Fprintf(w, "  %s to models without a known price are left out of the cost (+); set their prices under usage.prices\n", plural(total.Unpriced, "request"))
+	}
+	if total.Estimated > 0 {
+		fmt.Fprintf(w, "  %s estimated because the provider did not report usage\n", plural(total.Estimated, "request"))
+	}
+
+	if cfg.Usage != nil && cfg.Usage.MonthlyBudget > 0 {
+		// The budget is always for this month, whatever period is reported
+		if current, err := Read(MonthStart(time.Now())); err == nil {
+			spent := Sum(current, prices).Cost
+			fmt.Fprintf(w, "Budget: %s of %s spent this month (%.0f%%)\n", FormatCost(spent), FormatCost(cfg.Usage.MonthlyBudget), spent/cfg.Usage.MonthlyBudget*100)
+		}
+	}
+}
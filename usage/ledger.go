@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ask/config"
+)
+
+// Entry is the usage of a single request
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	ContextID        string    `json:"context_id,omitempty"`
+	ContextName      string    `json:"context_name,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"` // the provider did not report usage
+}
+
+// Path returns the path to the ledger, a file of JSON lines in the
+// configuration directory
+func Path() string {
+	return ledgerPath()
+}
+
+// ledgerPath is replaced by tests to use a temporary ledger
+var ledgerPath = func() string {
+	return filepath.Join(config.GetConfigDir(), "usage.jsonl")
+}
+
+// Append adds an entry to the ledger
+func Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(Path()), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	f, err := os.OpenFile(Path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %v", err)
+	}
+	return nil
+}
+
+// Read returns the entries recorded at or after since. Lines that cannot be
+// parsed are skipped.
+func Read(since time.Time) ([]Entry, error) {
+	f, err := os.Open(Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTempLedger points the ledger at a file in a temporary directory that
+// does not exist yet
+func useTempLedger(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ask", "usage.jsonl")
+	old := ledgerPath
+	ledgerPath = func() string { return path }
+	t.Cleanup(func() { ledgerPath = old })
+	return path
+}
+
+func TestAppendAndRead(t *testing.T) {
+	path := useTempLedger(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Provider: "openai", Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5},
+		{Time: start.Add(time.Hour), Provider: "anthropic", Model: "claude-sonnet-4-5", ContextID: "abc", ContextName: "work", PromptTokens: 20, CompletionTokens: 7},
+		{Time: start.Add(2 * time.Hour), Provider: "ollama", Model: "llama3", PromptTokens: 30, CompletionTokens: 9, Estimated: true},
+	}
+	for _, entry := range entries {
+		if err := Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("ledger not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("ledger permissions = %v, want 0600", perm)
+	}
+
+	all, err := Read(time.Time{})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(all) != len(entries) {
+		t.Fatalf("read %d entries, want %d", len(all), len(entries))
+	}
+	for i, entry := range entries {
+		if !all[i].Time.Equal(entry.Time) || all[i].Model != entry.Model || all[i].ContextName != entry.ContextName ||
+			all[i].PromptTokens != entry.PromptTokens || all[i].CompletionTokens != entry.CompletionTokens || all[i].Estimated != entry.Estimated {
+			t.Errorf("entry %d = %+v, want %+v", i, all[i], entry)
+		}
+	}
+
+	// Entries before since are left out, including the one just before it
+	recent, err := Read(start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Model != "claude-sonnet-4-5" {
+		t.Errorf("Read since the second entry = %+v, want the last two", recent)
+	}
+}
+
+func TestReadSkipsBadLines(t *testing.T) {
+	path := useTempLedger(t)
+	if err := Append(Entry{Time: time.Now(), Model: "gpt-4o", PromptTokens: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"time\": \"not a time\"\n")
+	f.Close()
+	if err := Append(Entry{Time: time.Now(), Model: "gpt-4o", PromptTokens: 2}); err != nil {
+		t.Fatalf("Append after a bad line: %v", err)
+	}
+
+	entries, err := Read(time.Time{})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 2 || entries[0].PromptTokens != 1 || entries[1].PromptTokens != 2 {
+		t.Errorf("Read = %+v, want both valid entries", entries)
+	}
+}
+
+func TestReadWithoutLedger(t *testing.T) {
+	useTempLedger(t)
+	entries, err := Read(time.Time{})
+	if err != nil || entries != nil {
+		t.Errorf("Read = %v, %v; want nothing without a ledger", entries, err)
+	}
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"strings"
+
+	"ask/config"
+)
+
+// DefaultPrices are list prices in US dollars per million tokens. Dated
+// snapshots such as gpt-4o-2024-08-06 use the price of the longest
+// matching name.
+var DefaultPrices = map[string]config.ModelPrice{
+	"gpt-5":             {Input: 1.25, Output: 10},
+	"gpt-5-mini":        {Input: 0.25, Output: 2},
+	"gpt-5-nano":        {Input: 0.05, Output: 0.40},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"gpt-4o":            {Input: 2.50, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4-turbo":       {Input: 10, Output: 30},
+	"gpt-4":             {Input: 30, Output: 60},
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"gpt-3.5-turbo-16k": {Input: 3, Output: 4},
+	"o1":                {Input: 15, Output: 60},
+	"o3":                {Input: 2, Output: 8},
+	"o3-mini":           {Input: 1.10, Output: 4.40},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"claude-opus-4-5":   {Input: 5, Output: 25},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-haiku-4-5":  {Input: 1, Output: 5},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+}
+
+// Prices is a price table
+type Prices map[string]config.ModelPrice
+
+// LoadPrices returns the default prices with the configured ones on top
+func LoadPrices(cfg *config.Config) Prices {
+	prices := Prices{}
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+	if cfg.Usage != nil {
+		for model, price := range cfg.Usage.Prices {
+			prices[model] = price
+		}
+	}
+	return prices
+}
+
+// Cost returns the cost of an entry in US dollars, and false if the price
+// of its model is unknown. Local models are free.
+func (p Prices) Cost(entry Entry) (float64, bool) {
+	price, ok := p.lookup(entry.Model)
+	if !ok {
+		if entry.Provider == config.ProviderOllama {
+			return 0, true
+		}
+		return 0, false
+	}
+	return (float64(entry.PromptTokens)*price.Input + float64(entry.CompletionTokens)*price.Output) / 1e6, true
+}
+
+// lookup finds the price of model by exact name, else by the longest name
+// it starts with
+func (p Prices) lookup(model string) (config.ModelPrice, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return p[best], true
+}
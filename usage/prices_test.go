@@ -0,0 +1,52 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"ask/config"
+)
+
+func TestCost(t *testing.T) {
+	prices := LoadPrices(&config.Config{})
+	tests := []struct {
+		provider, model string
+		want            float64 // for a million prompt and a million completion tokens
+		priced          bool
+	}{
+		{"openai", "gpt-4o", 12.50, true},
+		{"openai", "gpt-4o-2024-08-06", 12.50, true},
+		{"openai", "gpt-4o-mini-2024-07-18", 0.75, true},
+		{"openai", "gpt-4.5-preview", 0, false},
+		{"anthropic", "claude-opus-4-5", 30, true},
+		{"anthropic", "claude-opus-4-5-20251101", 30, true},
+		{"anthropic", "claude-opus-4-1", 90, true},
+		{"anthropic", "claude-opus-4-20250514", 90, true},
+		{"anthropic", "claude-sonnet-4-5-20250929", 18, true},
+		{"ollama", "llama3", 0, true},
+		{"openai", "my-model", 0, false},
+	}
+	for _, test := range tests {
+		cost, ok := prices.Cost(Entry{Provider: test.provider, Model: test.model, PromptTokens: 1e6, CompletionTokens: 1e6})
+		if ok != test.priced || math.Abs(cost-test.want) > 1e-9 {
+			t.Errorf("Cost(%s) = %v, %v; want %v, %v", test.model, cost, ok, test.want, test.priced)
+		}
+	}
+}
+
+func TestLoadPricesOverridesDefaults(t *testing.T) {
+	cfg := &config.Config{Usage: &config.UsageConfig{Prices: map[string]config.ModelPrice{
+		"gpt-4o":   {Input: 1, Output: 1},
+		"my-model": {Input: 2, Output: 4},
+	}}}
+	prices := LoadPrices(cfg)
+	if cost, _ := prices.Cost(Entry{Model: "gpt-4o-2024-08-06", PromptTokens: 1e6}); cost != 1 {
+		t.Errorf("configured gpt-4o cost = %v, want 1", cost)
+	}
+	if cost, ok := prices.Cost(Entry{Model: "my-model", CompletionTokens: 1e6}); !ok || cost != 4 {
+		t.Errorf("configured my-model cost = %v, %v; want 4", cost, ok)
+	}
+	if DefaultPrices["gpt-4o"].Input != 2.50 {
+		t.Error("configured prices changed the defaults")
+	}
+}
@@ -0,0 +1,177 @@
+package usage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Total sums the usage of a group of entries
+type Total struct {
+	Key              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Unpriced         int // requests whose cost is unknown
+	Estimated        int // requests whose tokens were estimated
+}
+
+func (t *Total) add(entry Entry, prices Prices) {
+	t.Requests++
+	t.PromptTokens += entry.PromptTokens
+	t.CompletionTokens += entry.CompletionTokens
+	if cost, ok := prices.Cost(entry); ok {
+		t.Cost += cost
+	} else {
+		t.Unpriced++
+	}
+	if entry.Estimated {
+		t.Estimated++
+	}
+}
+
+// Sum returns the total of entries
+func Sum(entries []Entry, prices Prices) Total {
+	var total Total
+	for _, entry := range entries {
+		total.add(entry, prices)
+	}
+	return total
+}
+
+// Group returns the totals of entries grouped by key, sorted by key
+func Group(entries []Entry, prices Prices, key func(Entry) string) []Total {
+	groups := map[string]*Total{}
+	for _, entry := range entries {
+		k := key(entry)
+		if groups[k] == nil {
+			groups[k] = &Total{Key: k}
+		}
+		groups[k].add(entry, prices)
+	}
+
+	var totals []Total
+	for _, total := range groups {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Key < totals[j].Key })
+	return totals
+}
+
+// ByDay groups entries by their local date
+func ByDay(entry Entry) string {
+	return entry.Time.Local().Format("2006-01-02")
+}
+
+// ByModel groups entries by provider and model
+func ByModel(entry Entry) string {
+	return entry.Provider + "/" + entry.Model
+}
+
+// ByContext groups entries by context name, or ID if it had none
+func ByContext(entry Entry) string {
+	switch {
+	case entry.ContextName != "":
+		return entry.ContextName
+	case entry.ContextID != "":
+		return entry.ContextID
+	}
+	return "(no context)"
+}
+
+// MonthStart returns the start of the month of t in local time
+func MonthStart(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// BudgetWarning returns a warning when the spending of the current month
+// has reached 80% of budget, and an empty string otherwise
+func BudgetWarning(budget float64, prices Prices, now time.Time) (string, error) {
+	if budget <= 0 {
+		return "", nil
+	}
+	entries, err := Read(MonthStart(now))
+	if err != nil {
+		return "", err
+	}
+	spent := Sum(entries, prices).Cost
+	switch {
+	case spent >= budget:
+		return fmt.Sprintf("💸 Monthly budget exceeded: spent %s of %s this month", FormatCost(spent), FormatCost(budget)), nil
+	case spent >= budget*0.8:
+		return fmt.Sprintf("⚠️  Spent %s of the %s monthly budget (%.0f%%)", FormatCost(spent), FormatCost(budget), spent/budget*100), nil
+	}
+	return "", nil
+}
+
+// writeTable prints totals as a table headed by title, with the keys
+// aligned left and the numbers right
+func writeTable(w io.Writer, title string, totals []Total) {
+	rows := [][]string{{"", "REQUESTS", "PROMPT", "COMPLETION", "COST"}}
+	for _, total := range totals {
+		rows = append(rows, []string{total.Key, strconv.Itoa(total.Requests), FormatCount(total.PromptTokens), FormatCount(total.CompletionTokens), costColumn(total)})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %-*s", widths[0], row[0])
+		for i := 1; i < len(row); i++ {
+			fmt.Fprintf(w, "  %*s", widths[i], row[i])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+}
+
+// costColumn shows a cost, marking totals that leave out unpriced requests
+func costColumn(total Total) string {
+	if total.Unpriced == total.Requests {
+		return "-"
+	}
+	if total.Unpriced > 0 {
+		return FormatCost(total.Cost) + "+"
+	}
+	return FormatCost(total.Cost)
+}
+
+// FormatCost formats a cost in US dollars
+func FormatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// plural returns "1 request" or "n requests"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// FormatCount formats a number with thousands separators
+func FormatCount(n int) string {
+	s := strconv.Itoa(n)
+	var out strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(c)
+	}
+	return out.String()
+}
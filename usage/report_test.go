@@ -0,0 +1,145 @@
+package usage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"ask/config"
+)
+
+// testPrices charges $1 per million prompt and $2 per million completion
+// tokens of test-model
+var testPrices = Prices{"test-model": {Input: 1, Output: 2}}
+
+func TestGroupAndSum(t *testing.T) {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	entries := []Entry{
+		{Time: day, Provider: "openai", Model: "test-model", ContextName: "work", PromptTokens: 1e6, CompletionTokens: 1e6},
+		{Time: day.AddDate(0, 0, 1), Provider: "openai", Model: "test-model", ContextID: "abc", PromptTokens: 2e6, Estimated: true},
+		{Time: day, Provider: "openai", Model: "unpriced", ContextName: "work", PromptTokens: 5, CompletionTokens: 5},
+		{Time: day, Provider: "ollama", Model: "llama3", PromptTokens: 7, CompletionTokens: 3},
+	}
+
+	total := Sum(entries, testPrices)
+	if total.Requests != 4 || total.PromptTokens != 3e6+12 || total.CompletionTokens != 1e6+8 ||
+		total.Cost != 5 || total.Unpriced != 1 || total.Estimated != 1 {
+		t.Errorf("Sum = %+v", total)
+	}
+
+	byModel := Group(entries, testPrices, ByModel)
+	if len(byModel) != 3 {
+		t.Fatalf("grouped by model into %+v, want 3 groups", byModel)
+	}
+	keys := []string{byModel[0].Key, byModel[1].Key, byModel[2].Key}
+	if strings.Join(keys, " ") != "ollama/llama3 openai/test-model openai/unpriced" {
+		t.Errorf("model groups = %v, want them sorted", keys)
+	}
+	if g := byModel[1]; g.Requests != 2 || g.Cost != 5 || g.Unpriced != 0 {
+		t.Errorf("openai/test-model = %+v, want 2 requests costing $5", g)
+	}
+	if g := byModel[2]; g.Cost != 0 || g.Unpriced != 1 {
+		t.Errorf("openai/unpriced = %+v, want it unpriced", g)
+	}
+
+	byDay := Group(entries, testPrices, ByDay)
+	if len(byDay) != 2 || byDay[0].Key != "2026-03-10" || byDay[0].Requests != 3 || byDay[1].Key != "2026-03-11" {
+		t.Errorf("grouped by day into %+v", byDay)
+	}
+
+	byContext := Group(entries, testPrices, ByContext)
+	if len(byContext) != 3 || byContext[0].Key != "(no context)" || byContext[1].Key != "abc" || byContext[2].Key != "work" || byContext[2].Requests != 2 {
+		t.Errorf("grouped by context into %+v", byContext)
+	}
+}
+
+func TestReport(t *testing.T) {
+	useTempLedger(t)
+	entries := []Entry{
+		{Time: time.Now(), Provider: "openai", Model: "test-model", PromptTokens: 1500000, CompletionTokens: 250000},
+		{Time: time.Now(), Provider: "openai", Model: "unpriced", PromptTokens: 10, CompletionTokens: 10, Estimated: true},
+	}
+	for _, entry := range entries {
+		if err := Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	cfg := &config.Config{Usage: &config.UsageConfig{MonthlyBudget: 10}}
+
+	var out bytes.Buffer
+	report(&out, cfg, entries, testPrices, "March 2026", "model")
+	got := out.String()
+	for _, want := range []string{
+		"📊 Usage for March 2026\n",
+		"By model:\n",
+		"openai/test-model         1  1,500,000     250,000  $2.00\n",
+		"openai/unpriced           1         10          10      -\n",
+		"Total: 2 requests, 1,500,010 prompt and 250,010 completion tokens, $2.00\n",
+		"1 request to models without a known price",
+		"1 request estimated",
+		"Budget: $2.00 of $10.00 spent this month (20%)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "By day") || strings.Contains(got, "By context") {
+		t.Errorf("report grouped by model has other tables:\n%s", got)
+	}
+}
+
+func TestBudgetWarning(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		spent []float64 // prompt tokens in millions, so dollars, this month
+		want  string
+	}{
+		{"nothing spent", nil, ""},
+		{"under 80%", []float64{3, 4.9}, ""},
+		{"at 80%", []float64{5, 3}, "⚠️  Spent $8.00 of the $10.00 monthly budget (80%)"},
+		{"almost all", []float64{9.5}, "⚠️  Spent $9.50 of the $10.00 monthly budget (95%)"},
+		{"at the budget", []float64{10}, "💸 Monthly budget exceeded: spent $10.00 of $10.00 this month"},
+		{"over it", []float64{6, 6}, "💸 Monthly budget exceeded: spent $12.00 of $10.00 this month"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTempLedger(t)
+			// Last month's spending does not count
+			if err := Append(Entry{Time: MonthStart(now).Add(-time.Second), Model: "test-model", PromptTokens: 100e6}); err != nil {
+				t.Fatal(err)
+			}
+			for _, spent := range test.spent {
+				if err := Append(Entry{Time: MonthStart(now), Model: "test-model", PromptTokens: int(spent * 1e6)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := BudgetWarning(10, testPrices, now)
+			if err != nil || got != test.want {
+				t.Errorf("BudgetWarning = %q, %v; want %q", got, err, test.want)
+			}
+		})
+	}
+
+	useTempLedger(t)
+	Append(Entry{Time: now, Model: "test-model", PromptTokens: 100e6})
+	if got, err := BudgetWarning(0, testPrices, now); got != "" || err != nil {
+		t.Errorf("BudgetWarning without a budget = %q, %v; want nothing", got, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	counts := map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567"}
+	for n, want := range counts {
+		if got := FormatCount(n); got != want {
+			t.Errorf("FormatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+	costs := map[float64]string{0: "$0.00", 0.0012: "$0.0012", 0.01: "$0.01", 12.5: "$12.50"}
+	for cost, want := range costs {
+		if got := FormatCost(cost); got != want {
+			t.Errorf("FormatCost(%v) = %q, want %q", cost, got, want)
+		}
+	}
+}
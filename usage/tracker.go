@@ -0,0 +1,46 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"ask/provider"
+	"ask/tokens"
+)
+
+// Tracker is a Provider that records the usage of every answer it returns
+// in the ledger. When the backend does not report usage it is estimated.
+type Tracker struct {
+	provider.Provider
+	ContextID   string
+	ContextName string
+	// Warn reports a failure to write the ledger
+	Warn func(err error)
+}
+
+func (t *Tracker) Chat(ctx context.Context, req *provider.Request, onDelta func(string)) (*provider.Response, error) {
+	resp, err := t.Provider.Chat(ctx, req, onDelta)
+	if resp == nil {
+		return resp, err
+	}
+
+	entry := Entry{
+		Time:        time.Now().UTC(),
+		Provider:    t.Provider.Name(),
+		Model:       req.Model,
+		ContextID:   t.ContextID,
+		ContextName: t.ContextName,
+	}
+	if resp.Usage != nil {
+		entry.PromptTokens = resp.Usage.PromptTokens
+		entry.CompletionTokens = resp.Usage.CompletionTokens
+	} else {
+		entry.PromptTokens = tokens.EstimateMessages(req.Model, req.Messages)
+		entry.CompletionTokens = tokens.Estimate(req.Model, resp.Content)
+		entry.Estimated = true
+	}
+	if appendErr := Append(entry); appendErr != nil && t.Warn != nil {
+		t.Warn(appendErr)
+	}
+	return resp, err
+}
@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ask/config"
+	"ask/provider"
+)
+
+// fakeProvider answers every request with resp and err
+type fakeProvider struct {
+	resp *provider.Response
+	err  error
+}
+
+func (f *fakeProvider) Name() string { return config.ProviderOllama }
+
+func (f *fakeProvider) Chat(ctx context.Context, req *provider.Request, onDelta func(string)) (*provider.Response, error) {
+	return f.resp, f.err
+}
+
+func TestTrackerRecordsUsage(t *testing.T) {
+	useTempLedger(t)
+	req := &provider.Request{Model: "llama3", Messages: []config.ChatMessage{{Role: "user", Content: "Why is the sky blue?"}}}
+
+	reported := &Tracker{Provider: &fakeProvider{resp: &provider.Response{Content: "Rayleigh scattering.", Usage: &provider.Usage{PromptTokens: 12, CompletionTokens: 4}}}, ContextID: "abc", ContextName: "work"}
+	if _, err := reported.Chat(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	unreported := &Tracker{Provider: &fakeProvider{resp: &provider.Response{Content: "Rayleigh scattering."}}}
+	if _, err := unreported.Chat(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	failed := &Tracker{Provider: &fakeProvider{err: errors.New("connection refused")}}
+	if _, err := failed.Chat(context.Background(), req, nil); err == nil {
+		t.Fatal("the provider's error was lost")
+	}
+
+	entries, err := Read(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d entries, want one per answer", len(entries))
+	}
+	if e := entries[0]; e.Provider != "ollama" || e.Model != "llama3" || e.ContextID != "abc" || e.ContextName != "work" ||
+		e.PromptTokens != 12 || e.CompletionTokens != 4 || e.Estimated {
+		t.Errorf("reported usage recorded as %+v", e)
+	}
+	if e := entries[1]; !e.Estimated || e.PromptTokens == 0 || e.CompletionTokens == 0 {
+		t.Errorf("unreported usage recorded as %+v, want an estimate", e)
+	}
+}
+
+func TestTrackerWarnsWhenTheLedgerFails(t *testing.T) {
+	old := ledgerPath
+	defer func() { ledgerPath = old }()
+	ledgerPath = func() string { return t.TempDir() } // a directory cannot be appended to
+
+	var warned error
+	tracker := &Tracker{Provider: &fakeProvider{resp: &provider.Response{Content: "hi"}}, Warn: func(err error) { warned = err }}
+	resp, err := tracker.Chat(context.Background(), &provider.Request{Model: "llama3"}, nil)
+	if err != nil || resp == nil || resp.Content != "hi" {
+		t.Errorf("Chat = %+v, %v; want the answer despite the ledger", resp, err)
+	}
+	if warned == nil {
+		t.Error("failing to write the ledger was not reported")
+	}
+}